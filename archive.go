@@ -0,0 +1,213 @@
+package standalone
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveFormat is the format of an asset, detected from its content.
+type archiveFormat string
+
+const (
+	formatUnknown    archiveFormat = ""
+	formatZip        archiveFormat = "zip"
+	formatGzip       archiveFormat = "gzip"
+	formatXz         archiveFormat = "xz"
+	formatZstd       archiveFormat = "zstd"
+	formatTar        archiveFormat = "tar"
+	formatExecutable archiveFormat = "executable"
+)
+
+// sniffLen is how many bytes are needed to detect every format. The tar
+// magic is at offset 257.
+const sniffLen = 512
+
+var (
+	magicZip      = []byte("PK\x03\x04")
+	magicZipEmpty = []byte("PK\x05\x06")
+	magicGzip     = []byte{0x1f, 0x8b}
+	magicXz       = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicTar      = []byte("ustar")
+
+	magicExecutables = [][]byte{
+		{0x7f, 'E', 'L', 'F'},    // ELF
+		{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32-bit
+		{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64-bit
+		{0xce, 0xfa, 0xed, 0xfe}, // Mach-O 32-bit, little endian
+		{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64-bit, little endian
+		{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+		[]byte("MZ"),             // PE
+	}
+)
+
+// ErrUnknownFormat is returned for assets that are neither a supported
+// archive nor an executable.
+var ErrUnknownFormat = errors.New("unknown archive format")
+
+func detectFormat(head []byte) archiveFormat {
+	switch {
+	case bytes.HasPrefix(head, magicZip), bytes.HasPrefix(head, magicZipEmpty):
+		return formatZip
+	case bytes.HasPrefix(head, magicGzip):
+		return formatGzip
+	case bytes.HasPrefix(head, magicXz):
+		return formatXz
+	case bytes.HasPrefix(head, magicZstd):
+		return formatZstd
+	case len(head) >= 262 && bytes.Equal(head[257:262], magicTar):
+		return formatTar
+	}
+	for _, magic := range magicExecutables {
+		if bytes.HasPrefix(head, magic) {
+			return formatExecutable
+		}
+	}
+	return formatUnknown
+}
+
+// extractArchive extracts the asset called name into dest, laid out
+// according to l, and returns the paths of the files written. The format
+// is detected from the content, not the name: zip, tar compressed with
+// gzip, xz or zstd, plain tar, or a single executable, optionally
+// compressed.
+func extractArchive(ctx context.Context, name string, r io.Reader, dest string, l layout) ([]string, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch format := detectFormat(head); format {
+	case formatZip:
+		// Zip needs random access. Embedded files and byte slices provide
+		// it, so only plain streams are read into memory.
+		ra, size, err := readerAt(r)
+		if err != nil {
+			return nil, err
+		}
+		if ra == nil {
+			fileBytes, err := io.ReadAll(contextReader{ctx, br})
+			if err != nil {
+				return nil, err
+			}
+			ra, size = bytes.NewReader(fileBytes), int64(len(fileBytes))
+		}
+		return unzip(ctx, ra, size, dest, l)
+	case formatGzip, formatXz, formatZstd:
+		decompressed, err := decompress(format, br)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		defer decompressed.Close()
+		return extractDecompressed(ctx, trimCompressionExt(name), decompressed, dest, l)
+	default:
+		return extractDecompressed(ctx, name, br, dest, l)
+	}
+}
+
+// readerAt returns random access to r and its size if r supports it
+// directly or by seeking, as embedded files do. It returns a nil
+// io.ReaderAt otherwise.
+func readerAt(r io.Reader) (io.ReaderAt, int64, error) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		return nil, 0, nil
+	}
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra, size, nil
+	}
+	return &seekReaderAt{rs: rs}, size, nil
+}
+
+// seekReaderAt implements io.ReaderAt on top of an io.ReadSeeker.
+type seekReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// extractDecompressed extracts a tar or writes a single executable.
+func extractDecompressed(ctx context.Context, name string, r io.Reader, dest string, l layout) ([]string, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch detectFormat(head) {
+	case formatTar:
+		return extractTar(ctx, br, dest, l)
+	case formatExecutable:
+		// A single executable has no path to strip.
+		x, err := newExtractor(ctx, dest, layout{Dir: l.Dir})
+		if err != nil {
+			return nil, err
+		}
+		if err = x.file(filepath.Base(name), 0755, br); err != nil {
+			return nil, err
+		}
+		return x.files, nil
+	default:
+		return nil, fmt.Errorf("%s: %w", name, ErrUnknownFormat)
+	}
+}
+
+func decompress(format archiveFormat, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case formatGzip:
+		return gzip.NewReader(r)
+	case formatXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case formatZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// trimCompressionExt returns the name of the decompressed asset, e.g.
+// "daprd.gz" becomes "daprd".
+func trimCompressionExt(name string) string {
+	for _, ext := range []string{".gz", ".tgz", ".xz", ".txz", ".zst", ".tzst"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
@@ -0,0 +1,139 @@
+package standalone
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tar := make([]byte, sniffLen)
+	copy(tar[257:], magicTar)
+	tests := []struct {
+		name string
+		head []byte
+		want archiveFormat
+	}{
+		{"zip", []byte("PK\x03\x04rest"), formatZip},
+		{"empty zip", []byte("PK\x05\x06"), formatZip},
+		{"gzip", []byte{0x1f, 0x8b, 0x08}, formatGzip},
+		{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00}, formatXz},
+		{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, formatZstd},
+		{"tar", tar, formatTar},
+		{"ELF", []byte("\x7fELF\x02\x01"), formatExecutable},
+		{"Mach-O", []byte{0xcf, 0xfa, 0xed, 0xfe, 0x07}, formatExecutable},
+		{"Mach-O universal", []byte{0xca, 0xfe, 0xba, 0xbe}, formatExecutable},
+		{"PE", []byte("MZ\x90\x00"), formatExecutable},
+		{"text", []byte("<html>Not Found</html>"), formatUnknown},
+		{"short", []byte("P"), formatUnknown},
+		{"empty", nil, formatUnknown},
+	}
+	for _, tt := range tests {
+		if got := detectFormat(tt.head); got != tt.want {
+			t.Errorf("detectFormat(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractArchiveFormats(t *testing.T) {
+	tarball := tarArchive(t, dirEntry("bin"), fileEntry("bin/dapr", "dapr"), fileEntry("bin/daprd", "daprd"))
+	zipball := zipArchive(t, dirEntry("bin"), fileEntry("bin/dapr", "dapr"), fileEntry("bin/daprd", "daprd"))
+	executable := "\x7fELF daprd"
+	extracted := []string{"bin/dapr", "bin/daprd"}
+
+	tests := []struct {
+		name    string
+		content []byte
+		// stream hides the random access of the archive.
+		stream  bool
+		want    []string
+		wantErr error
+	}{
+		{name: "dapr.zip", content: zipball, want: extracted},
+		{name: "dapr.zip", content: zipball, stream: true, want: extracted},
+		{name: "dapr.tar.gz", content: compress(t, formatGzip, tarball), want: extracted},
+		{name: "dapr.tar.xz", content: compress(t, formatXz, tarball), want: extracted},
+		{name: "dapr.tar.zst", content: compress(t, formatZstd, tarball), want: extracted},
+		{name: "dapr.tar", content: tarball, want: extracted},
+		// The format is detected from the content, not the name.
+		{name: "dapr.zip", content: compress(t, formatGzip, tarball), want: extracted},
+		{name: "daprd", content: []byte(executable), want: []string{"daprd"}},
+		{name: "daprd.gz", content: compress(t, formatGzip, []byte(executable)), want: []string{"daprd"}},
+		{name: "daprd.xz", content: compress(t, formatXz, []byte(executable)), want: []string{"daprd"}},
+		{name: "error.html", content: []byte("<html>Not Found</html>"), wantErr: ErrUnknownFormat},
+		{name: "text.gz", content: compress(t, formatGzip, []byte("not an archive")), wantErr: ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		if tt.stream {
+			name += " stream"
+		}
+		t.Run(name, func(t *testing.T) {
+			dest := t.TempDir()
+			var r io.Reader = bytes.NewReader(tt.content)
+			if tt.stream {
+				r = struct{ io.Reader }{r}
+			}
+			files, err := extractArchive(context.Background(), tt.name, r, dest, layout{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("extractArchive() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, f := range files {
+				rel, err := filepath.Rel(dest, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !sameStrings(got, tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+			// A single executable is written executable.
+			if len(files) == 1 && runtime.GOOS != "windows" {
+				fi, err := os.Stat(files[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm()&0111 == 0 {
+					t.Errorf("%s is not executable: %v", files[0], fi.Mode())
+				}
+			}
+		})
+	}
+}
+
+func compress(t testing.TB, format archiveFormat, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch format {
+	case formatGzip:
+		w = gzip.NewWriter(&buf)
+	case formatXz:
+		w, err = xz.NewWriter(&buf)
+	case formatZstd:
+		w, err = zstd.NewWriter(&buf)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -4,8 +4,8 @@ import (
 	"embed"
 )
 
-//go:embed cli/darwin_arm64/dapr_darwin_arm64.tar.gz
-var cliBinary []byte
-
-//go:embed binaries/darwin_arm64
-var binaries embed.FS
+// assets holds the CLI and binary archives of every bundled release at
+// their paths in the release manifest.
+//
+//go:embed cli/darwin_arm64 binaries/darwin_arm64
+var assets embed.FS
@@ -4,8 +4,8 @@ import (
 	"embed"
 )
 
-//go:embed cli/linux_amd64/dapr_linux_amd64.tar.gz
-var cliBinary []byte
-
-//go:embed binaries/linux_amd64
-var binaries embed.FS
+// assets holds the CLI and binary archives of every bundled release at
+// their paths in the release manifest.
+//
+//go:embed cli/linux_amd64 binaries/linux_amd64
+var assets embed.FS
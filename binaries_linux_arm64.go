@@ -4,8 +4,8 @@ import (
 	"embed"
 )
 
-//go:embed cli/linux_arm64/dapr_linux_arm64.tar.gz
-var cliBinary []byte
-
-//go:embed binaries/linux_amd64
-var binaries embed.FS
+// assets holds the CLI and binary archives of every bundled release at
+// their paths in the release manifest.
+//
+//go:embed cli/linux_arm64 binaries/linux_arm64
+var assets embed.FS
@@ -4,8 +4,8 @@ import (
 	"embed"
 )
 
-//go:embed cli/windows_amd64/dapr_windows_amd64.zip
-var cliBinary []byte
-
-//go:embed binaries/windows_amd64
-var binaries embed.FS
+// assets holds the CLI and binary archives of every bundled release at
+// their paths in the release manifest.
+//
+//go:embed cli/windows_amd64 binaries/windows_amd64
+var assets embed.FS
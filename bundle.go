@@ -0,0 +1,230 @@
+package standalone
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// bundleJSON is the release manifest written by tools/prepare.go. It
+// describes the releases and assets embedded in the installer.
+//
+//go:embed bundle.json
+var bundleJSON []byte
+
+// bundleSignature is the detached ed25519 signature of bundleJSON, base64
+// encoded. It is empty if the installer was built without a signing key.
+//
+//go:embed bundle.json.sig
+var bundleSignature []byte
+
+// bundlePublicKey is the base64 encoded ed25519 public key that must have
+// signed bundleJSON. It is set at build time with
+// -ldflags "-X github.com/dapr/standalone.bundlePublicKey=...".
+var bundlePublicKey = ""
+
+// ErrVersionNotBundled is returned when the selected version is not
+// bundled with the installer.
+var ErrVersionNotBundled = errors.New("version is not bundled")
+
+// bundleManifest is the content of bundleJSON.
+type bundleManifest struct {
+	// Default is the version installed unless another one is selected.
+	Default  string   `json:"default"`
+	Releases []bundle `json:"releases"`
+}
+
+// bundle is a bundled release. Releases share the assets and images they
+// have in common, so several of them may refer to the same path.
+type bundle struct {
+	// Version is the Dapr version of the release.
+	Version string        `json:"version"`
+	Assets  []bundleAsset `json:"assets"`
+	Images  []bundleImage `json:"images"`
+}
+
+// bundleAsset is an embedded CLI or binary archive.
+type bundleAsset struct {
+	// Path is the path of the archive in the embedded filesystem,
+	// e.g. "binaries/linux_amd64/daprd_linux_amd64.tar.gz".
+	Path string `json:"path"`
+	// URL is where the archive was downloaded from.
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	layout
+}
+
+// layout says where the entries of an archive are extracted to.
+type layout struct {
+	// StripComponents is the number of leading path components removed
+	// from the entry names, like tar --strip-components. Entries with no
+	// components left are skipped.
+	StripComponents int `json:"stripComponents,omitempty"`
+	// StripPrefix is a leading path, e.g. "release/linux_amd64", removed
+	// from the entry names before StripComponents. Entries outside of it
+	// are skipped.
+	StripPrefix string `json:"stripPrefix,omitempty"`
+	// Dir is the directory, relative to the destination, the entries are
+	// extracted to.
+	Dir string `json:"dir,omitempty"`
+}
+
+// bundleImage is an image archive in the images directory.
+type bundleImage struct {
+	// Role is the service the image runs.
+	Role Service `json:"role"`
+	// Image is the image reference, e.g. "daprio/placement:1.6.0".
+	Image string `json:"image"`
+	// File is the name of the archive in the images directory.
+	File string `json:"file"`
+	// Digest is the repo digest Image resolved to when it was bundled,
+	// e.g. "redis@sha256:...".
+	Digest string `json:"digest,omitempty"`
+	// ID is the image ID. Containers are run by ID so that they use
+	// exactly the image that was bundled, even for floating tags.
+	ID string `json:"id,omitempty"`
+	// SHA256 is the checksum of File.
+	SHA256 string `json:"sha256"`
+}
+
+func loadBundleManifest() (*bundleManifest, error) {
+	var m bundleManifest
+	if err := json.Unmarshal(bundleJSON, &m); err != nil {
+		return nil, fmt.Errorf("could not read the embedded release manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// loadBundle returns the bundled release of version, or the default
+// release if version is empty.
+func loadBundle(version string) (*bundle, error) {
+	m, err := loadBundleManifest()
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = m.Default
+	}
+	versions := make([]string, 0, len(m.Releases))
+	for idx := range m.Releases {
+		if m.Releases[idx].Version == version {
+			return &m.Releases[idx], nil
+		}
+		versions = append(versions, m.Releases[idx].Version)
+	}
+	return nil, fmt.Errorf("%w: %s, this installer bundles %s", ErrVersionNotBundled, version, strings.Join(versions, ", "))
+}
+
+// BundledVersions returns the Dapr versions bundled with the installer and
+// the one installed by default.
+func BundledVersions() ([]string, string, error) {
+	m, err := loadBundleManifest()
+	if err != nil {
+		return nil, "", err
+	}
+	versions := make([]string, 0, len(m.Releases))
+	for _, r := range m.Releases {
+		versions = append(versions, r.Version)
+	}
+	return versions, m.Default, nil
+}
+
+// image returns the image that serves role.
+func (b *bundle) image(role Service) (bundleImage, error) {
+	for _, img := range b.Images {
+		if img.Role == role {
+			return img, nil
+		}
+	}
+	return bundleImage{}, fmt.Errorf("the installer does not bundle an image for %s", role)
+}
+
+// cliAsset returns the CLI archive for the current platform.
+func (b *bundle) cliAsset() (bundleAsset, error) {
+	assets := b.platformAssets("cli")
+	if len(assets) != 1 {
+		return bundleAsset{}, fmt.Errorf("the release manifest lists %d CLI archives for %s", len(assets), osarch)
+	}
+	return assets[0], nil
+}
+
+// binaryAssets returns the binary archives for the current platform.
+func (b *bundle) binaryAssets() []bundleAsset {
+	return b.platformAssets("binaries")
+}
+
+// platformAssets returns the assets in dir for the current platform, the
+// only ones embedded in the installer.
+func (b *bundle) platformAssets(dir string) []bundleAsset {
+	prefix := path.Join(dir, osarch) + "/"
+	var assets []bundleAsset
+	for _, a := range b.Assets {
+		if strings.HasPrefix(a.Path, prefix) {
+			assets = append(assets, a)
+		}
+	}
+	return assets
+}
+
+// asset returns the asset at p.
+func (b *bundle) asset(p string) (bundleAsset, error) {
+	for _, a := range b.Assets {
+		if a.Path == p {
+			return a, nil
+		}
+	}
+	return bundleAsset{}, fmt.Errorf("%s is not in the release manifest", p)
+}
+
+// runRef returns the reference to run the image by.
+func (img bundleImage) runRef() string {
+	if img.ID != "" {
+		return img.ID
+	}
+	return img.Image
+}
+
+// sameImageID compares image IDs. Podman omits the "sha256:" prefix.
+func sameImageID(a, b string) bool {
+	return strings.TrimPrefix(a, "sha256:") == strings.TrimPrefix(b, "sha256:")
+}
+
+// normalizeImageRef expands an image reference to its fully qualified
+// form, e.g. "redis:latest" to "docker.io/library/redis:latest". Podman
+// lists images by their qualified names while Docker does not.
+func normalizeImageRef(ref string) string {
+	name := ref
+	if idx := strings.LastIndex(ref, "@"); idx >= 0 {
+		name = ref[:idx]
+	}
+	if !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") && !strings.Contains(ref, "@") {
+		ref += ":latest"
+	}
+
+	parts := strings.SplitN(ref, "/", 2)
+	switch {
+	case len(parts) == 1:
+		return "docker.io/library/" + ref
+	case !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost":
+		return "docker.io/" + ref
+	}
+	return ref
+}
+
+// imageSet is a set of normalized image references.
+type imageSet map[string]bool
+
+func newImageSet(images map[string]bool) imageSet {
+	set := imageSet{}
+	for image := range images {
+		set[normalizeImageRef(image)] = true
+	}
+	return set
+}
+
+func (s imageSet) contains(image string) bool {
+	return s[normalizeImageRef(image)]
+}
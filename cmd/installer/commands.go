@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dapr/standalone"
+)
+
+func install(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	fs := newFlagSet("install")
+	versionFlag(fs, &opts)
+	installDirFlag(fs, &opts)
+	networkFlag(fs, &opts)
+	runtimeName := runtimeFlag(fs)
+	fs.IntVar(&opts.PlacementPort, "placement-port", 0, "host port of the placement service (default 50005, 6050 on Windows)")
+	fs.IntVar(&opts.RedisPort, "redis-port", 0, "host port of redis (default 6379)")
+	fs.IntVar(&opts.ZipkinPort, "zipkin-port", 0, "host port of zipkin (default 9411)")
+	fs.BoolVar(&opts.AutoPorts, "auto-ports", false, "use free ports instead of failing when a port is in use")
+	skip := fs.String("skip", "", "comma separated services not to start: placement, redis, zipkin")
+	fs.DurationVar(&opts.ReadyTimeout, "ready-timeout", 0, "how long to wait for each service to be ready (default 1m)")
+	fs.DurationVar(&opts.StepTimeout, "step-timeout", 0, "how long each install step, e.g. loading an image, may take (default 10m)")
+	dryRun := fs.Bool("dry-run", false, "print what would be installed and exit without changing anything")
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	services, err := skipServices(*skip)
+	if err != nil {
+		return err
+	}
+
+	opts.Services = services
+	opts.Observer = observer(*output)
+	installer, err := newInstaller(opts, *runtimeName)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		return plan(ctx, installer, *output)
+	}
+	return installer.Install(ctx)
+}
+
+func plan(ctx context.Context, installer *standalone.Installer, output string) error {
+	p, err := installer.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		return p.WriteJSON(os.Stdout)
+	}
+	return p.WriteText(os.Stdout)
+}
+
+func uninstall(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	var uo standalone.UninstallOptions
+	fs := newFlagSet("uninstall")
+	installDirFlag(fs, &opts)
+	networkFlag(fs, &opts)
+	runtimeName := runtimeFlag(fs)
+	fs.BoolVar(&uo.RemoveImages, "remove-images", false, "remove the images used by the service containers")
+	fs.BoolVar(&uo.RemoveFiles, "remove-files", false, "remove the binaries, config.yaml and generated components")
+	fs.BoolVar(&uo.Purge, "purge", false, "remove the whole install directory, including user-authored components")
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	opts.Observer = observer(*output)
+	installer, err := newInstaller(opts, *runtimeName)
+	if err != nil {
+		return err
+	}
+	return installer.Uninstall(ctx, uo)
+}
+
+func upgrade(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	fs := newFlagSet("upgrade")
+	versionFlag(fs, &opts)
+	installDirFlag(fs, &opts)
+	fs.StringVar(&opts.From, "from", "", "installed version of an install without a manifest (default: the runtime version dapr --version shows)")
+	networkFlag(fs, &opts)
+	runtimeName := runtimeFlag(fs)
+	fs.DurationVar(&opts.ReadyTimeout, "ready-timeout", 0, "how long to wait for each service to be ready (default 1m)")
+	fs.DurationVar(&opts.StepTimeout, "step-timeout", 0, "how long each install step, e.g. loading an image, may take (default 10m)")
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	opts.Observer = observer(*output)
+	installer, err := newInstaller(opts, *runtimeName)
+	if err != nil {
+		return err
+	}
+	return installer.Upgrade(ctx)
+}
+
+func list(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	fs := newFlagSet("list")
+	installDirFlag(fs, &opts)
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	installer, err := standalone.NewInstaller(opts)
+	if err != nil {
+		return err
+	}
+	versions, err := installer.Versions()
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		if versions == nil {
+			versions = []standalone.InstalledVersion{}
+		}
+		return writeJSON(versions)
+	}
+
+	if len(versions) == 0 {
+		fmt.Println("No versions are installed")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tVERSION\tINSTALLED")
+	for _, v := range versions {
+		current := ""
+		if v.Current {
+			current = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", current, v.Version, v.InstalledAt.Local().Format(time.RFC1123))
+	}
+	return tw.Flush()
+}
+
+func use(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	fs := newFlagSet("use")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s use [flags] <version>\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	installDirFlag(fs, &opts)
+	runtimeName := runtimeFlag(fs)
+	output := outputFlag(fs)
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(errors.New("the version to use is required"))
+	}
+
+	opts.Observer = observer(*output)
+	installer, err := newInstaller(opts, *runtimeName)
+	if err != nil {
+		return err
+	}
+	return installer.Use(ctx, fs.Arg(0))
+}
+
+func status(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	fs := newFlagSet("status")
+	installDirFlag(fs, &opts)
+	runtimeName := runtimeFlag(fs)
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	installer, err := newInstaller(opts, *runtimeName)
+	if err != nil {
+		return err
+	}
+	s, err := installer.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		err = writeJSON(s)
+	} else {
+		err = writeStatus(s)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !s.Installed:
+		return &exitError{code: exitNotInstalled}
+	case !s.Healthy():
+		return &exitError{code: exitDegraded}
+	}
+	return nil
+}
+
+func writeStatus(s *standalone.Status) error {
+	if !s.Installed {
+		fmt.Printf("Dapr is not installed in %s\n", s.InstallDir)
+		return nil
+	}
+	fmt.Printf("Dapr %s installed in %s at %s\n", s.Version, s.InstallDir, s.InstalledAt.Local().Format(time.RFC1123))
+	if !s.Complete {
+		fmt.Printf("The install did not complete: %s\n", s.Error)
+	}
+	if s.Network != "" {
+		fmt.Printf("Network: %s\n", s.Network)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(s.Files) > 0 {
+		fmt.Fprintln(tw, "\nFiles:")
+		for _, f := range s.Files {
+			fmt.Fprintf(tw, "  %s\t%s\n", f.State, f.Path)
+		}
+	}
+	if len(s.Containers) > 0 {
+		fmt.Fprintln(tw, "\nContainers:")
+		for _, c := range s.Containers {
+			state := "running"
+			if !c.Exists {
+				state = "missing"
+			} else if !c.Running {
+				state = "stopped"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", c.Service, c.Name, state, c.Image)
+		}
+	}
+	return tw.Flush()
+}
+
+func verify(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	fs := newFlagSet("verify")
+	fs.StringVar(&opts.Version, "version", "", "bundled Dapr version to verify (default: all)")
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	o := observer(*output)
+	opts.Observer = o
+	installer, err := standalone.NewInstaller(opts)
+	if err != nil {
+		return err
+	}
+	if err = installer.Verify(ctx); err != nil {
+		return err
+	}
+	o.OnEvent(standalone.Event{Kind: standalone.EventDone, Time: time.Now(), Message: "The bundled assets are intact."})
+	return nil
+}
+
+// bundledRelease is a bundled release in the output of list-assets.
+type bundledRelease struct {
+	Version string                    `json:"version"`
+	Default bool                      `json:"default"`
+	Assets  []standalone.BundledAsset `json:"assets"`
+}
+
+func listAssets(ctx context.Context, args []string) error {
+	fs := newFlagSet("list-assets")
+	only := fs.String("version", "", "bundled Dapr version to list the assets of (default: all)")
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	versions, defaultVersion, err := standalone.BundledVersions()
+	if err != nil {
+		return err
+	}
+	if *only != "" {
+		versions = []string{*only}
+	}
+	releases := []bundledRelease{}
+	for _, v := range versions {
+		_, assets, err := standalone.BundledAssets(v)
+		if err != nil {
+			return err
+		}
+		releases = append(releases, bundledRelease{Version: v, Default: v == defaultVersion, Assets: assets})
+	}
+	if *output == "json" {
+		return writeJSON(releases)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for idx, r := range releases {
+		if idx > 0 {
+			fmt.Fprintln(tw)
+		}
+		if r.Default {
+			fmt.Fprintf(tw, "Dapr %s (default)\n\n", r.Version)
+		} else {
+			fmt.Fprintf(tw, "Dapr %s\n\n", r.Version)
+		}
+		fmt.Fprintln(tw, "KIND\tNAME\tSIZE\tSHA256")
+		for _, a := range r.Assets {
+			name := a.Path
+			if a.Image != "" {
+				name = a.Image
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", a.Kind, name, a.Size, a.SHA256)
+		}
+	}
+	return tw.Flush()
+}
+
+func extract(ctx context.Context, args []string) error {
+	var opts standalone.Options
+	fs := newFlagSet("extract")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s extract [flags] <dir>\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.Version, "version", "", "bundled Dapr version to extract (default: the default version, see list-assets)")
+	output := outputFlag(fs)
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(errors.New("the directory to extract into is required"))
+	}
+	dir := fs.Arg(0)
+
+	o := observer(*output)
+	opts.Observer = o
+	installer, err := standalone.NewInstaller(opts)
+	if err != nil {
+		return err
+	}
+	files, err := installer.Extract(ctx, dir)
+	if err != nil {
+		return err
+	}
+	o.OnEvent(standalone.Event{Kind: standalone.EventDone, Time: time.Now(), Message: fmt.Sprintf("Extracted %d files into %s", len(files), dir)})
+	return nil
+}
+
+func printVersion(ctx context.Context, args []string) error {
+	fs := newFlagSet("version")
+	output := outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	versions, defaultVersion, err := standalone.BundledVersions()
+	if err != nil {
+		return err
+	}
+	installerVersion := version
+	if installerVersion == "" {
+		installerVersion = "unknown"
+	}
+	if *output == "json" {
+		return writeJSON(struct {
+			Installer string   `json:"installer"`
+			Dapr      []string `json:"dapr"`
+			Default   string   `json:"default"`
+		}{installerVersion, versions, defaultVersion})
+	}
+	fmt.Printf("Installer: %s\nDapr:      %s (default %s)\n", installerVersion, strings.Join(versions, ", "), defaultVersion)
+	return nil
+}
+
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
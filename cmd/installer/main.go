@@ -1,18 +1,259 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/dapr/standalone"
 )
 
 var version = ""
 
+// Exit codes. Scripts can rely on them to tell failures apart.
+const (
+	exitOK           = 0
+	exitFailure      = 1
+	exitUsage        = 2
+	exitVerification = 3
+	exitRuntime      = 4
+	exitPortConflict = 5
+	exitNotReady     = 6
+	exitNotInstalled = 7
+	exitDegraded     = 8
+	exitInterrupted  = 130
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"install", "install Dapr and start the service containers (default)", install},
+	{"uninstall", "remove the service containers and optionally the images and files", uninstall},
+	{"list", "list the installed versions", list},
+	{"upgrade", "upgrade the installed version, migrating its configuration", upgrade},
+	{"use", "switch to an installed version", use},
+	{"status", "show the installed version, files and containers", status},
+	{"verify", "check the signature and checksums of the bundled assets", verify},
+	{"list-assets", "list the bundled CLI, binaries and images", listAssets},
+	{"extract", "extract the CLI and binaries into a directory", extract},
+	{"version", "print the installer and bundled Dapr versions", printVersion},
+}
+
 func main() {
-	if version == "" {
-		log.Fatal("version is not set")
+	log.SetFlags(0)
+	os.Exit(run(signalContext(), os.Args[1:]))
+}
+
+// run runs the command named by the first argument and returns the exit
+// code. Without a command, or if the first argument is a flag, the
+// install command is run.
+func run(ctx context.Context, args []string) int {
+	name := "install"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage(os.Stdout)
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(ctx, args)
+			code := exitCode(ctx, err)
+			var ee *exitError
+			if err != nil && !(errors.As(err, &ee) && ee.err == nil) && !errors.Is(err, flag.ErrHelp) {
+				log.Print(err)
+			}
+			return code
+		}
+	}
+
+	log.Printf("unknown command %q", name)
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.short)
 	}
-	if err := standalone.Install(version); err != nil {
-		log.Fatal(err)
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
+}
+
+// exitError makes the command exit with code. err is logged unless nil.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageError wraps err so that the command exits with exitUsage.
+func usageError(err error) error {
+	return &exitError{code: exitUsage, err: err}
+}
+
+// exitCode maps err to the exit code of the command.
+func exitCode(ctx context.Context, err error) int {
+	var (
+		ee       *exitError
+		checksum *standalone.ChecksumError
+		conflict *standalone.PortConflictError
+		notReady *standalone.NotReadyError
+	)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &ee):
+		return ee.code
+	case ctx.Err() != nil && errors.Is(err, ctx.Err()):
+		return exitInterrupted
+	case errors.As(err, &checksum), errors.Is(err, standalone.ErrSignature):
+		return exitVerification
+	case errors.Is(err, standalone.ErrNoContainerRuntime), errors.Is(err, standalone.ErrDaemonNotRunning):
+		return exitRuntime
+	case errors.As(err, &conflict), errors.Is(err, standalone.ErrPortAllocated):
+		return exitPortConflict
+	case errors.As(err, &notReady), errors.Is(err, standalone.ErrCrashLoop):
+		return exitNotReady
+	case errors.Is(err, standalone.ErrVersionNotBundled):
+		return exitUsage
+	case errors.Is(err, standalone.ErrVersionNotInstalled), errors.Is(err, standalone.ErrNotInstalled):
+		return exitNotInstalled
+	}
+	return exitFailure
+}
+
+// signalContext returns a context that is canceled on the first interrupt
+// so that the install is rolled back. A second interrupt exits immediately.
+func signalContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		fmt.Fprintln(os.Stderr, "Interrupted, cleaning up. Press Ctrl-C again to exit immediately.")
+	}()
+	return ctx
+}
+
+// newFlagSet returns a flag set for the command that reports errors
+// instead of exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]\n\nFlags:\n", os.Args[0], name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args, rejecting positional arguments beyond max.
+func parse(fs *flag.FlagSet, args []string, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		// The flag package already printed the error and usage.
+		return &exitError{code: exitUsage}
+	}
+	if fs.NArg() > max {
+		return usageError(fmt.Errorf("unexpected argument %q", fs.Arg(max)))
+	}
+	return nil
+}
+
+func versionFlag(fs *flag.FlagSet, opts *standalone.Options) {
+	fs.StringVar(&opts.Version, "version", "", "bundled Dapr version to install (default: the default version, see list-assets)")
+}
+
+func installDirFlag(fs *flag.FlagSet, opts *standalone.Options) {
+	fs.StringVar(&opts.InstallDir, "install-dir", "", "root of the installation (default $DAPR_HOME or ~/.dapr)")
+}
+
+func networkFlag(fs *flag.FlagSet, opts *standalone.Options) {
+	fs.StringVar(&opts.Network, "network", "", "docker network to attach the containers to, created if missing")
+}
+
+func runtimeFlag(fs *flag.FlagSet) *string {
+	return fs.String("runtime", "", "container runtime to use: docker, podman, nerdctl or docker-engine (default: auto-detect)")
+}
+
+func outputFlag(fs *flag.FlagSet) *string {
+	return fs.String("output", "text", "output format: text or json")
+}
+
+func checkOutput(output string) error {
+	if output != "text" && output != "json" {
+		return usageError(fmt.Errorf("unknown output format %q", output))
+	}
+	return nil
+}
+
+// observer returns the observer that renders progress in the output format.
+func observer(output string) standalone.Observer {
+	if output == "json" {
+		return standalone.NewJSONObserver(os.Stdout)
+	}
+	return standalone.NewConsoleObserver(os.Stdout)
+}
+
+// skipServices returns all services except those in the comma separated list.
+func skipServices(skip string) ([]standalone.Service, error) {
+	if skip == "" {
+		return nil, nil
+	}
+	skipped := map[standalone.Service]bool{}
+	for _, name := range strings.Split(skip, ",") {
+		s := standalone.Service(strings.TrimSpace(name))
+		known := false
+		for _, v := range standalone.AllServices {
+			known = known || v == s
+		}
+		if !known {
+			return nil, usageError(fmt.Errorf("unknown service %q", s))
+		}
+		skipped[s] = true
+	}
+	services := []standalone.Service{}
+	for _, s := range standalone.AllServices {
+		if !skipped[s] {
+			services = append(services, s)
+		}
+	}
+	return services, nil
+}
+
+func newInstaller(opts standalone.Options, runtimeName string) (*standalone.Installer, error) {
+	if runtimeName != "" {
+		rt, err := standalone.NewContainerRuntime(runtimeName)
+		if err != nil {
+			return nil, usageError(err)
+		}
+		opts.ContainerRuntime = rt
 	}
+	return standalone.NewInstaller(opts)
 }
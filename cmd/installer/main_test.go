@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dapr/standalone"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	// The Docker Engine API is not listening there.
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(dir, "docker.sock"))
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "help", args: []string{"help"}, want: exitOK},
+		{name: "command help", args: []string{"list", "-h"}, want: exitOK},
+		{name: "list", args: []string{"list", "--install-dir", dir}, want: exitOK},
+		{name: "unknown command", args: []string{"frobnicate"}, want: exitUsage},
+		{name: "unknown flag", args: []string{"--frobnicate"}, want: exitUsage},
+		{name: "unexpected argument", args: []string{"list", "v1.6.0"}, want: exitUsage},
+		{name: "missing argument", args: []string{"use"}, want: exitUsage},
+		{name: "unknown output", args: []string{"version", "--output", "xml"}, want: exitUsage},
+		{name: "unknown service", args: []string{"install", "--skip", "mysql"}, want: exitUsage},
+		{name: "unknown runtime", args: []string{"install", "--runtime", "rkt"}, want: exitUsage},
+		{name: "version not bundled", args: []string{"install", "--install-dir", dir, "--version", "v0.0.1"}, want: exitUsage},
+		{name: "status not installed", args: []string{"status", "--install-dir", dir}, want: exitNotInstalled},
+		{name: "upgrade not installed", args: []string{"upgrade", "--install-dir", dir, "--network", "n"}, want: exitNotInstalled},
+		{name: "use not installed", args: []string{"use", "--install-dir", dir, "v1.6.0"}, want: exitNotInstalled},
+		{name: "daemon not running", args: []string{"uninstall", "--install-dir", dir, "--runtime", "docker-engine"}, want: exitRuntime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(context.Background(), tt.args); got != tt.want {
+				t.Errorf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDispatch(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		args []string
+		err  error
+		// wantCommand and wantArgs are the command run and its arguments.
+		wantCommand string
+		wantArgs    []string
+		want        int
+	}{
+		{name: "default command", wantCommand: "install", want: exitOK},
+		{name: "flags of the default command", args: []string{"--version", "v1.6.0"}, wantCommand: "install", wantArgs: []string{"--version", "v1.6.0"}, want: exitOK},
+		{name: "command", args: []string{"use", "v1.6.0"}, wantCommand: "use", wantArgs: []string{"v1.6.0"}, want: exitOK},
+		{name: "failure", args: []string{"list"}, err: errors.New("failed"), wantCommand: "list", want: exitFailure},
+		{name: "exit error", args: []string{"status"}, err: &exitError{code: exitDegraded}, wantCommand: "status", want: exitDegraded},
+		{name: "version not bundled", err: fmt.Errorf("%w: v0.0.1", standalone.ErrVersionNotBundled), wantCommand: "install", want: exitUsage},
+		{name: "checksum", args: []string{"verify"}, err: &standalone.ChecksumError{Path: "cli"}, wantCommand: "verify", want: exitVerification},
+		{name: "signature", args: []string{"verify"}, err: fmt.Errorf("refusing to install: %w", standalone.ErrSignature), wantCommand: "verify", want: exitVerification},
+		{name: "no runtime", err: standalone.ErrNoContainerRuntime, wantCommand: "install", want: exitRuntime},
+		{name: "daemon not running", args: []string{"uninstall"}, err: fmt.Errorf("could not list containers: %w", standalone.ErrDaemonNotRunning), wantCommand: "uninstall", want: exitRuntime},
+		{name: "port allocated", err: fmt.Errorf("could not start redis: %w", standalone.ErrPortAllocated), wantCommand: "install", want: exitPortConflict},
+		{name: "port conflict", err: &standalone.PortConflictError{Service: standalone.ServiceRedis, Port: 6379}, wantCommand: "install", want: exitPortConflict},
+		{name: "not ready", err: &standalone.NotReadyError{Service: standalone.ServiceRedis, Err: errors.New("timed out")}, wantCommand: "install", want: exitNotReady},
+		{name: "crash loop", args: []string{"use", "v1.6.0"}, err: standalone.ErrCrashLoop, wantCommand: "use", wantArgs: []string{"v1.6.0"}, want: exitNotReady},
+		{name: "not installed", args: []string{"upgrade"}, err: standalone.ErrNotInstalled, wantCommand: "upgrade", want: exitNotInstalled},
+		{name: "version not installed", args: []string{"use", "v1.5.1"}, err: standalone.ErrVersionNotInstalled, wantCommand: "use", wantArgs: []string{"v1.5.1"}, want: exitNotInstalled},
+		{name: "interrupted", ctx: canceled, err: fmt.Errorf("could not load images: %w", context.Canceled), wantCommand: "install", want: exitInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran string
+			var ranArgs []string
+			saved := commands
+			defer func() { commands = saved }()
+			commands = nil
+			for _, cmd := range saved {
+				name := cmd.name
+				commands = append(commands, command{name: name, run: func(ctx context.Context, args []string) error {
+					ran, ranArgs = name, args
+					return tt.err
+				}})
+			}
+
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if got := run(ctx, tt.args); got != tt.want {
+				t.Errorf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+			if ran != tt.wantCommand || len(ranArgs)+len(tt.wantArgs) > 0 && !reflect.DeepEqual(ranArgs, tt.wantArgs) {
+				t.Errorf("ran %s %q, want %s %q", ran, ranArgs, tt.wantCommand, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package standalone
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	// ErrNameConflict is returned when a container with the same name already exists.
+	ErrNameConflict = errors.New("container name already in use")
+	// ErrPortAllocated is returned when a host port is already bound.
+	ErrPortAllocated = errors.New("port already allocated")
+	// ErrDaemonNotRunning is returned when the container daemon cannot be reached.
+	ErrDaemonNotRunning = errors.New("container daemon not running")
+)
+
+// EngineError is an error response from the Docker Engine API.
+type EngineError struct {
+	StatusCode int
+	Message    string
+	// Err is one of the typed errors above, or nil.
+	Err error
+}
+
+func (e *EngineError) Error() string {
+	return fmt.Sprintf("docker engine: %s (status %d)", e.Message, e.StatusCode)
+}
+
+func (e *EngineError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	// RuntimeDockerEngine talks to the Docker Engine API directly.
+	RuntimeDockerEngine = "docker-engine"
+
+	defaultDockerHost = "unix:///var/run/docker.sock"
+)
+
+// engineRuntime implements ContainerRuntime on top of the Docker Engine
+// HTTP API instead of the docker CLI.
+type engineRuntime struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewEngineRuntime returns a runtime that uses the Docker Engine API at
+// host, e.g. "unix:///var/run/docker.sock" or "tcp://127.0.0.1:2375".
+// When host is empty, DOCKER_HOST is used, falling back to the default socket.
+func NewEngineRuntime(host string) (ContainerRuntime, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &engineRuntime{
+			baseURL: "http://docker",
+			client:  &http.Client{Transport: transport},
+		}, nil
+	case "tcp", "http":
+		return &engineRuntime{
+			baseURL: "http://" + u.Host,
+			client:  http.DefaultClient,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q", host)
+	}
+}
+
+func (r *engineRuntime) Name() string {
+	return "docker"
+}
+
+func (r *engineRuntime) Load(ctx context.Context, in io.Reader, out io.Writer) ([]string, error) {
+	resp, err := r.do(ctx, http.MethodPost, "/images/load", nil, "application/x-tar", in)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The response is a stream of JSON messages.
+	var output strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var msg struct {
+			Stream      string `json:"stream"`
+			ErrorDetail *struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err = json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.ErrorDetail != nil {
+			return nil, &EngineError{StatusCode: resp.StatusCode, Message: msg.ErrorDetail.Message}
+		}
+		fmt.Fprint(out, msg.Stream)
+		output.WriteString(msg.Stream)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return parseLoadedImages(output.String()), nil
+}
+
+func (r *engineRuntime) Images(ctx context.Context) (map[string]bool, error) {
+	var list []struct {
+		RepoTags []string `json:"RepoTags"`
+	}
+	if err := r.getJSON(ctx, "/images/json", &list); err != nil {
+		return nil, fmt.Errorf("unable to list images: %w", err)
+	}
+	images := map[string]bool{}
+	for _, img := range list {
+		for _, tag := range img.RepoTags {
+			images[tag] = true
+		}
+	}
+	return images, nil
+}
+
+func (r *engineRuntime) RemoveImage(ctx context.Context, image string) error {
+	return r.call(ctx, http.MethodDelete, "/images/"+image, nil, nil)
+}
+
+func (r *engineRuntime) ImageID(ctx context.Context, image string) (string, error) {
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	if err := r.getJSON(ctx, "/images/"+image+"/json", &resp); err != nil {
+		return "", fmt.Errorf("unable to inspect image %s: %w", image, err)
+	}
+	return resp.ID, nil
+}
+
+func (r *engineRuntime) Run(ctx context.Context, spec ContainerSpec) error {
+	type endpointSettings struct {
+		Aliases []string `json:"Aliases,omitempty"`
+	}
+	type portBinding struct {
+		HostPort string `json:"HostPort"`
+	}
+	var body struct {
+		Image        string              `json:"Image"`
+		Entrypoint   []string            `json:"Entrypoint,omitempty"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+		HostConfig   struct {
+			NetworkMode   string                   `json:"NetworkMode,omitempty"`
+			PortBindings  map[string][]portBinding `json:"PortBindings,omitempty"`
+			RestartPolicy struct {
+				Name string `json:"Name,omitempty"`
+			} `json:"RestartPolicy"`
+		} `json:"HostConfig"`
+		NetworkingConfig struct {
+			EndpointsConfig map[string]endpointSettings `json:"EndpointsConfig,omitempty"`
+		} `json:"NetworkingConfig"`
+	}
+
+	body.Image = spec.Image
+	if spec.Entrypoint != "" {
+		body.Entrypoint = []string{spec.Entrypoint}
+	}
+	body.HostConfig.RestartPolicy.Name = spec.Restart
+	if spec.Network != "" {
+		body.HostConfig.NetworkMode = spec.Network
+		if spec.NetworkAlias != "" {
+			body.NetworkingConfig.EndpointsConfig = map[string]endpointSettings{
+				spec.Network: {Aliases: []string{spec.NetworkAlias}},
+			}
+		}
+	}
+	if len(spec.Ports) > 0 {
+		body.ExposedPorts = map[string]struct{}{}
+		body.HostConfig.PortBindings = map[string][]portBinding{}
+		for _, p := range spec.Ports {
+			port := fmt.Sprintf("%d/tcp", p.ContainerPort)
+			body.ExposedPorts[port] = struct{}{}
+			body.HostConfig.PortBindings[port] = append(body.HostConfig.PortBindings[port],
+				portBinding{HostPort: strconv.Itoa(p.HostPort)})
+		}
+	}
+
+	query := url.Values{"name": {spec.Name}}
+	if err := r.call(ctx, http.MethodPost, "/containers/create", query, body); err != nil {
+		return err
+	}
+	if err := r.Start(ctx, spec.Name); err != nil {
+		// Do not leave a created but never started container behind,
+		// even if ctx was canceled.
+		_ = r.Remove(context.Background(), spec.Name)
+		return err
+	}
+	return nil
+}
+
+func (r *engineRuntime) Start(ctx context.Context, name string) error {
+	return r.call(ctx, http.MethodPost, "/containers/"+name+"/start", nil, nil)
+}
+
+func (r *engineRuntime) Stop(ctx context.Context, name string) error {
+	return r.call(ctx, http.MethodPost, "/containers/"+name+"/stop", nil, nil)
+}
+
+func (r *engineRuntime) Rename(ctx context.Context, name, newName string) error {
+	return r.call(ctx, http.MethodPost, "/containers/"+name+"/rename", url.Values{"name": {newName}}, nil)
+}
+
+func (r *engineRuntime) Remove(ctx context.Context, name string) error {
+	return r.call(ctx, http.MethodDelete, "/containers/"+name, url.Values{"force": {"true"}}, nil)
+}
+
+func (r *engineRuntime) Inspect(ctx context.Context, name string) (ContainerInfo, error) {
+	var resp struct {
+		State struct {
+			Running    bool `json:"Running"`
+			Restarting bool `json:"Restarting"`
+		} `json:"State"`
+		RestartCount int `json:"RestartCount"`
+		Config       struct {
+			Image string `json:"Image"`
+		} `json:"Config"`
+		HostConfig struct {
+			PortBindings map[string][]struct {
+				HostPort string `json:"HostPort"`
+			} `json:"PortBindings"`
+		} `json:"HostConfig"`
+	}
+	err := r.getJSON(ctx, "/containers/"+name+"/json", &resp)
+	var engineErr *EngineError
+	if errors.As(err, &engineErr) && engineErr.StatusCode == http.StatusNotFound {
+		return ContainerInfo{}, nil
+	} else if err != nil {
+		return ContainerInfo{}, fmt.Errorf("unable to inspect container %s: %w", name, err)
+	}
+	info := ContainerInfo{
+		Exists:       true,
+		Running:      resp.State.Running,
+		Restarting:   resp.State.Restarting,
+		RestartCount: resp.RestartCount,
+		Image:        resp.Config.Image,
+	}
+	for containerPort, bindings := range resp.HostConfig.PortBindings {
+		for _, b := range bindings {
+			if p, ok := parsePortBinding(b.HostPort, containerPort); ok {
+				info.Ports = append(info.Ports, p)
+			}
+		}
+	}
+	return info, nil
+}
+
+func (r *engineRuntime) NetworkExists(ctx context.Context, name string) (bool, error) {
+	var resp struct{}
+	err := r.getJSON(ctx, "/networks/"+name, &resp)
+	var engineErr *EngineError
+	if errors.As(err, &engineErr) && engineErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("unable to inspect network %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (r *engineRuntime) CreateNetwork(ctx context.Context, name string) error {
+	body := struct {
+		Name           string `json:"Name"`
+		CheckDuplicate bool   `json:"CheckDuplicate"`
+	}{Name: name, CheckDuplicate: true}
+	return r.call(ctx, http.MethodPost, "/networks/create", nil, body)
+}
+
+func (r *engineRuntime) RemoveNetwork(ctx context.Context, name string) error {
+	return r.call(ctx, http.MethodDelete, "/networks/"+name, nil, nil)
+}
+
+func (r *engineRuntime) getJSON(ctx context.Context, path string, v interface{}) error {
+	resp, err := r.do(ctx, http.MethodGet, path, nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// call sends body as JSON, if not nil, and discards the response.
+func (r *engineRuntime) call(ctx context.Context, method, path string, query url.Values, body interface{}) error {
+	var in io.Reader
+	contentType := ""
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		in = bytes.NewReader(b)
+		contentType = "application/json"
+	}
+	resp, err := r.do(ctx, method, path, query, contentType, in)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a request and converts error responses to an EngineError.
+// 304 Not Modified, e.g. starting a running container, is not an error.
+func (r *engineRuntime) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	u := r.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if isDaemonUnreachable(err) {
+			return nil, fmt.Errorf("%w: %v", ErrDaemonNotRunning, err)
+		}
+		return nil, err
+	}
+	if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var msg struct {
+		Message string `json:"message"`
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(b, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(b))
+	}
+	return nil, &EngineError{
+		StatusCode: resp.StatusCode,
+		Message:    msg.Message,
+		Err:        classifyEngineError(resp.StatusCode, msg.Message),
+	}
+}
+
+func classifyEngineError(statusCode int, message string) error {
+	if statusCode == http.StatusConflict && strings.Contains(message, "is already in use") {
+		return ErrNameConflict
+	}
+	return classifyErrorMessage(message)
+}
+
+// classifyErrorMessage maps the error messages of the daemon, which the
+// CLIs pass through, to the typed errors.
+func classifyErrorMessage(message string) error {
+	switch {
+	case strings.Contains(message, "is already in use by container"):
+		return ErrNameConflict
+	case strings.Contains(message, "port is already allocated"),
+		strings.Contains(message, "address already in use"):
+		return ErrPortAllocated
+	case strings.Contains(message, "Cannot connect to the Docker daemon"),
+		strings.Contains(message, "Is the docker daemon running"):
+		return ErrDaemonNotRunning
+	}
+	return nil
+}
+
+func isDaemonUnreachable(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT)
+}
@@ -0,0 +1,380 @@
+package standalone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// engineResponse is the response of the fake Docker Engine to a request.
+type engineResponse struct {
+	status int
+	body   string
+}
+
+// fakeEngine is a Docker Engine API server that answers requests, keyed
+// by their method and path, e.g. "POST /containers/create", with the
+// responses in the map and 204 No Content otherwise.
+type fakeEngine struct {
+	responses map[string]engineResponse
+	// requests records the method, path and query of every request.
+	requests []string
+	// bodies records the request bodies by method and path.
+	bodies map[string]string
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.Path
+	req := key
+	if r.URL.RawQuery != "" {
+		req += "?" + r.URL.RawQuery
+	}
+	f.requests = append(f.requests, req)
+	b, _ := io.ReadAll(r.Body)
+	f.bodies[key] = string(b)
+
+	resp, ok := f.responses[key]
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(resp.status)
+	io.WriteString(w, resp.body)
+}
+
+// newFakeEngine starts a fake Docker Engine and returns a runtime that
+// talks to it over TCP.
+func newFakeEngine(t *testing.T, responses map[string]engineResponse) (*fakeEngine, ContainerRuntime) {
+	t.Helper()
+	f := &fakeEngine{responses: responses, bodies: map[string]string{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	rt, err := NewEngineRuntime("tcp://" + srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, rt
+}
+
+func TestEngineErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response engineResponse
+		// call is the request answered with the response.
+		call    string
+		do      func(ctx context.Context, rt ContainerRuntime) error
+		wantErr error
+		// wantStatus is the status of the EngineError, 0 for none.
+		wantStatus int
+	}{
+		{
+			name:     "name conflict",
+			call:     "POST /containers/create",
+			response: engineResponse{http.StatusConflict, `{"message":"Conflict. The container name \"/dapr_redis\" is already in use by container \"0123\". You have to remove (or rename) that container to be able to reuse that name."}`},
+			do: func(ctx context.Context, rt ContainerRuntime) error {
+				return rt.Run(ctx, ContainerSpec{Name: "dapr_redis", Image: "redis"})
+			},
+			wantErr:    ErrNameConflict,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:     "port allocated",
+			call:     "POST /containers/dapr_redis/start",
+			response: engineResponse{http.StatusInternalServerError, `{"message":"driver failed programming external connectivity on endpoint dapr_redis: Bind for 0.0.0.0:6379 failed: port is already allocated"}`},
+			do: func(ctx context.Context, rt ContainerRuntime) error {
+				return rt.Start(ctx, "dapr_redis")
+			},
+			wantErr:    ErrPortAllocated,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:     "address in use",
+			call:     "POST /containers/dapr_redis/start",
+			response: engineResponse{http.StatusInternalServerError, `{"message":"listen tcp4 0.0.0.0:6379: bind: address already in use"}`},
+			do: func(ctx context.Context, rt ContainerRuntime) error {
+				return rt.Start(ctx, "dapr_redis")
+			},
+			wantErr:    ErrPortAllocated,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:     "untyped error",
+			call:     "DELETE /images/redis",
+			response: engineResponse{http.StatusInternalServerError, "image is being used"},
+			do: func(ctx context.Context, rt ContainerRuntime) error {
+				return rt.RemoveImage(ctx, "redis")
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:     "already started",
+			call:     "POST /containers/dapr_redis/start",
+			response: engineResponse{http.StatusNotModified, ""},
+			do: func(ctx context.Context, rt ContainerRuntime) error {
+				return rt.Start(ctx, "dapr_redis")
+			},
+		},
+		{
+			name:     "container not found",
+			call:     "GET /containers/dapr_redis/json",
+			response: engineResponse{http.StatusNotFound, `{"message":"No such container: dapr_redis"}`},
+			do: func(ctx context.Context, rt ContainerRuntime) error {
+				info, err := rt.Inspect(ctx, "dapr_redis")
+				if err == nil && info.Exists {
+					return errors.New("the container exists")
+				}
+				return err
+			},
+		},
+		{
+			name:     "network not found",
+			call:     "GET /networks/n",
+			response: engineResponse{http.StatusNotFound, `{"message":"network n not found"}`},
+			do: func(ctx context.Context, rt ContainerRuntime) error {
+				exists, err := rt.NetworkExists(ctx, "n")
+				if err == nil && exists {
+					return errors.New("the network exists")
+				}
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rt := newFakeEngine(t, map[string]engineResponse{tt.call: tt.response})
+			err := tt.do(context.Background(), rt)
+
+			var engineErr *EngineError
+			switch {
+			case tt.wantStatus == 0 && err != nil:
+				t.Fatalf("error = %v, want nil", err)
+			case tt.wantStatus == 0:
+				return
+			case !errors.As(err, &engineErr):
+				t.Fatalf("error = %v, want an EngineError", err)
+			case engineErr.StatusCode != tt.wantStatus:
+				t.Errorf("status = %d, want %d", engineErr.StatusCode, tt.wantStatus)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && engineErr.Err != nil {
+				t.Errorf("error = %v, want no typed error", engineErr.Err)
+			}
+		})
+	}
+}
+
+func TestEngineDaemonNotRunning(t *testing.T) {
+	tests := []struct {
+		name string
+		host func(t *testing.T) string
+	}{
+		{
+			name: "refused connection",
+			host: func(t *testing.T) string {
+				l, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				addr := l.Addr().String()
+				l.Close()
+				return "tcp://" + addr
+			},
+		},
+		{
+			name: "missing socket",
+			host: func(t *testing.T) string {
+				if runtime.GOOS == "windows" {
+					t.Skip("the engine listens on a named pipe on Windows")
+				}
+				return "unix://" + filepath.Join(t.TempDir(), "docker.sock")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := NewEngineRuntime(tt.host(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = rt.Inspect(context.Background(), "dapr_redis"); !errors.Is(err, ErrDaemonNotRunning) {
+				t.Errorf("Inspect() error = %v, want %v", err, ErrDaemonNotRunning)
+			}
+		})
+	}
+}
+
+func TestEngineUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the engine listens on a named pipe on Windows")
+	}
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	f := &fakeEngine{responses: map[string]engineResponse{
+		"GET /containers/dapr_redis/json": {http.StatusOK, `{"State":{"Running":true},"Config":{"Image":"redis:6"},` +
+			`"HostConfig":{"PortBindings":{"6379/tcp":[{"HostIp":"","HostPort":"6380"}],"6380/tcp":[{"HostPort":""}]}}}`},
+	}, bodies: map[string]string{}}
+	srv := &httptest.Server{Listener: l, Config: &http.Server{Handler: f}}
+	srv.Start()
+	defer srv.Close()
+
+	rt, err := NewEngineRuntime("unix://" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := rt.Inspect(context.Background(), "dapr_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ContainerInfo{Exists: true, Running: true, Image: "redis:6", Ports: []PortBinding{{HostPort: 6380, ContainerPort: 6379}}}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Inspect() = %+v, want %+v", info, want)
+	}
+}
+
+func TestEngineRun(t *testing.T) {
+	spec := ContainerSpec{
+		Name:         "dapr_redis_n",
+		Image:        "redis:6",
+		Network:      "n",
+		NetworkAlias: "dapr_redis",
+		Ports:        []PortBinding{{HostPort: 6380, ContainerPort: 6379}},
+		Restart:      "always",
+	}
+	tests := []struct {
+		name      string
+		responses map[string]engineResponse
+		wantErr   error
+		want      []string
+	}{
+		{
+			name: "create and start",
+			want: []string{
+				"POST /containers/create?name=dapr_redis_n",
+				"POST /containers/dapr_redis_n/start",
+			},
+		},
+		{
+			name: "create fails",
+			responses: map[string]engineResponse{
+				"POST /containers/create": {http.StatusConflict, `{"message":"The container name \"/dapr_redis_n\" is already in use"}`},
+			},
+			wantErr: ErrNameConflict,
+			want:    []string{"POST /containers/create?name=dapr_redis_n"},
+		},
+		{
+			name: "start fails",
+			responses: map[string]engineResponse{
+				"POST /containers/dapr_redis_n/start": {http.StatusInternalServerError, `{"message":"Bind for 0.0.0.0:6380 failed: port is already allocated"}`},
+			},
+			wantErr: ErrPortAllocated,
+			want: []string{
+				"POST /containers/create?name=dapr_redis_n",
+				"POST /containers/dapr_redis_n/start",
+				"DELETE /containers/dapr_redis_n?force=true",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, rt := newFakeEngine(t, tt.responses)
+			err := rt.Run(context.Background(), spec)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if strings.Join(f.requests, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("requests =\n%s\nwant\n%s", strings.Join(f.requests, "\n"), strings.Join(tt.want, "\n"))
+			}
+
+			var body struct {
+				Image      string
+				HostConfig struct {
+					NetworkMode   string
+					PortBindings  map[string][]struct{ HostPort string }
+					RestartPolicy struct{ Name string }
+				}
+				NetworkingConfig struct {
+					EndpointsConfig map[string]struct{ Aliases []string }
+				}
+			}
+			if err = json.Unmarshal([]byte(f.bodies["POST /containers/create"]), &body); err != nil {
+				t.Fatal(err)
+			}
+			hc := body.HostConfig
+			if body.Image != spec.Image || hc.NetworkMode != "n" || hc.RestartPolicy.Name != "always" {
+				t.Errorf("create body = %+v", body)
+			}
+			if b := hc.PortBindings["6379/tcp"]; len(b) != 1 || b[0].HostPort != "6380" {
+				t.Errorf("port bindings = %v, want 6380 for 6379/tcp", hc.PortBindings)
+			}
+			if a := body.NetworkingConfig.EndpointsConfig["n"].Aliases; len(a) != 1 || a[0] != "dapr_redis" {
+				t.Errorf("aliases = %v, want dapr_redis", a)
+			}
+		})
+	}
+}
+
+func TestEngineLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "loaded",
+			stream: `{"stream":"Loaded image: redis:6\n"}` + "\n" + `{"stream":"Loaded image: openzipkin/zipkin:2\n"}` + "\n",
+			want:   []string{"redis:6", "openzipkin/zipkin:2"},
+		},
+		{
+			name:    "error in the stream",
+			stream:  `{"stream":"Loading layer\n"}` + "\n" + `{"errorDetail":{"message":"unexpected EOF"},"error":"unexpected EOF"}` + "\n",
+			wantErr: "unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, rt := newFakeEngine(t, map[string]engineResponse{
+				"POST /images/load": {http.StatusOK, tt.stream},
+			})
+			var out strings.Builder
+			got, err := rt.Load(context.Background(), strings.NewReader("archive"), &out)
+			if f.bodies["POST /images/load"] != "archive" {
+				t.Errorf("load body = %q, want the archive", f.bodies["POST /images/load"])
+			}
+			if tt.wantErr != "" {
+				var engineErr *EngineError
+				if !errors.As(err, &engineErr) || engineErr.Message != tt.wantErr {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				// The status of a streamed error is that of the stream.
+				if engineErr.StatusCode != http.StatusOK {
+					t.Errorf("status = %d", engineErr.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(out.String(), "Loaded image: redis:6") {
+				t.Errorf("output = %q", out.String())
+			}
+		})
+	}
+}
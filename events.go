@@ -0,0 +1,182 @@
+package standalone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventKind identifies what an Event reports.
+type EventKind string
+
+const (
+	// EventStep is reported when a step starts, e.g. "Installing binaries...".
+	EventStep EventKind = "step"
+	// EventItem is an item within the current step.
+	EventItem EventKind = "item"
+	// EventMessage is an informational message.
+	EventMessage EventKind = "message"
+	// EventWarning is a problem that does not stop the operation.
+	EventWarning EventKind = "warning"
+	// EventOutput is output of the container runtime, e.g. while loading
+	// an image.
+	EventOutput EventKind = "output"
+	// EventExtracted is reported after an archive was extracted, with the
+	// number of bytes written.
+	EventExtracted EventKind = "extracted"
+	// EventImageLoading and EventImageLoaded are reported around loading
+	// an image.
+	EventImageLoading EventKind = "imageLoading"
+	EventImageLoaded  EventKind = "imageLoaded"
+	// EventContainerStarted is reported once the container of a service
+	// was started.
+	EventContainerStarted EventKind = "containerStarted"
+	// EventServiceWaiting is followed by EventServiceReady or
+	// EventServiceFailed once the service answers or gives up.
+	EventServiceWaiting EventKind = "serviceWaiting"
+	EventServiceReady   EventKind = "serviceReady"
+	EventServiceFailed  EventKind = "serviceFailed"
+	// EventDone is reported when the operation succeeded.
+	EventDone EventKind = "done"
+)
+
+// Event reports the progress of an install or uninstall.
+type Event struct {
+	Kind EventKind `json:"kind"`
+	Time time.Time `json:"time"`
+	// Message is the human readable text of the event.
+	Message string  `json:"message,omitempty"`
+	Service Service `json:"service,omitempty"`
+	// Name is the file, archive, image, network or container the event
+	// is about.
+	Name     string        `json:"name,omitempty"`
+	Bytes    int64         `json:"bytes,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Observer receives the events of an Installer. The events are delivered
+// in order from the goroutine running the operation.
+type Observer interface {
+	OnEvent(e Event)
+}
+
+// ObserverFunc adapts a function to an Observer, e.g. to forward the
+// events to a channel.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+type consoleObserver struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewConsoleObserver returns an Observer that writes the events to out as
+// human readable progress output. It is used by default.
+func NewConsoleObserver(out io.Writer) Observer {
+	return &consoleObserver{out: out}
+}
+
+func (o *consoleObserver) OnEvent(e Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch e.Kind {
+	case EventStep, EventMessage:
+		fmt.Fprintln(o.out, e.Message)
+	case EventItem:
+		fmt.Fprintf(o.out, "  • %s\n", e.Message)
+	case EventWarning:
+		fmt.Fprintf(o.out, "Warning: %s\n", e.Message)
+	case EventOutput:
+		fmt.Fprint(o.out, e.Message)
+	case EventImageLoading, EventServiceWaiting:
+		fmt.Fprintf(o.out, "  • %s... ", e.Message)
+	case EventServiceReady:
+		fmt.Fprintf(o.out, "ready (%s)\n", e.Duration.Round(100*time.Millisecond))
+	case EventServiceFailed:
+		fmt.Fprintln(o.out, "failed")
+	case EventDone:
+		fmt.Fprintf(o.out, "\n%s\n\n", e.Message)
+	}
+}
+
+type jsonObserver struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONObserver returns an Observer that writes each event to out as a
+// line of JSON.
+func NewJSONObserver(out io.Writer) Observer {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	return &jsonObserver{enc: enc}
+}
+
+func (o *jsonObserver) OnEvent(e Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Progress reporting must not fail the operation.
+	_ = o.enc.Encode(e)
+}
+
+// emit reports an event to the observer.
+func (i *Installer) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	i.opts.Observer.OnEvent(e)
+}
+
+func (i *Installer) step(format string, args ...interface{}) {
+	i.emit(Event{Kind: EventStep, Message: fmt.Sprintf(format, args...)})
+}
+
+func (i *Installer) item(format string, args ...interface{}) {
+	i.emit(Event{Kind: EventItem, Message: fmt.Sprintf(format, args...)})
+}
+
+func (i *Installer) message(format string, args ...interface{}) {
+	i.emit(Event{Kind: EventMessage, Message: fmt.Sprintf(format, args...)})
+}
+
+func (i *Installer) warn(format string, args ...interface{}) {
+	i.emit(Event{Kind: EventWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// outputBuffer collects the output of the container runtime. The runtime
+// may write to it from several goroutines, e.g. os/exec copies stdout and
+// stderr concurrently, so the output is reported by flushOutput instead.
+type outputBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// flushOutput reports the output collected in b as EventOutput, one line
+// per event.
+func (i *Installer) flushOutput(b *outputBuffer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.buf.Len() > 0 {
+		line, err := b.buf.ReadString('\n')
+		if err != nil {
+			// The last line is not terminated.
+			line += "\n"
+		}
+		i.emit(Event{Kind: EventOutput, Message: line})
+	}
+}
@@ -0,0 +1,75 @@
+package standalone
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// chattyRuntime writes to the output of Load from two goroutines, as
+// os/exec does for the stdout and stderr of the CLI.
+type chattyRuntime struct {
+	*fakeRuntime
+}
+
+func (r chattyRuntime) Load(ctx context.Context, in io.Reader, out io.Writer) ([]string, error) {
+	var wg sync.WaitGroup
+	for _, stream := range []string{"stdout", "stderr"} {
+		stream := stream
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				fmt.Fprintf(out, "%s %d\n", stream, n)
+			}
+		}()
+	}
+	wg.Wait()
+	return r.fakeRuntime.Load(ctx, in, out)
+}
+
+// Run with -race to check that the observer is not called concurrently.
+func TestLoadOutputEvents(t *testing.T) {
+	useTestBundle(t, "v1.6.0")
+	rt := newFakeRuntime()
+	rt.queueLoads("v1.6.0", AllServices...)
+	var events []Event
+	i, err := NewInstaller(Options{
+		InstallDir:       t.TempDir(),
+		Network:          "n",
+		ContainerRuntime: chattyRuntime{rt},
+		Observer:         ObserverFunc(func(e Event) { events = append(events, e) }),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = i.Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := map[string]int{}
+	loading := false
+	for _, e := range events {
+		switch e.Kind {
+		case EventImageLoading:
+			loading = true
+		case EventImageLoaded:
+			loading = false
+		case EventOutput:
+			if !loading {
+				t.Errorf("output %q outside of loading an image", e.Message)
+			}
+			lines[e.Message]++
+		}
+	}
+	for _, stream := range []string{"stdout", "stderr"} {
+		for n := 0; n < 100; n++ {
+			line := fmt.Sprintf("%s %d\n", stream, n)
+			if lines[line] != len(AllServices) {
+				t.Errorf("%q reported %d times, want %d", line, lines[line], len(AllServices))
+			}
+		}
+	}
+}
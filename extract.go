@@ -0,0 +1,451 @@
+package standalone
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// extractor writes archive entries below a destination directory. It is
+// shared by the tar and zip formats and refuses any entry that would end
+// up outside the destination, whether through its name, a symlink target
+// or a hard link.
+type extractor struct {
+	ctx    context.Context
+	dest   string
+	layout layout
+	// prefix is layout.StripPrefix split into path components.
+	prefix []string
+	// files lists the regular files written.
+	files []string
+	// links holds the symlinks created, which later entries may not replace.
+	links map[string]bool
+	buf   []byte
+}
+
+// copyBufferSize is the size of the buffer files are streamed through.
+const copyBufferSize = 64 * 1024
+
+// contextReader fails reads once ctx is done, which stops long copies.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// onlyReader hides any WriterTo of the wrapped reader, which would make
+// io.CopyBuffer ignore the buffer.
+type onlyReader struct {
+	io.Reader
+}
+
+func newExtractor(ctx context.Context, dest string, l layout) (*extractor, error) {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+	// Resolve the destination itself, e.g. /tmp on macOS, so that
+	// resolved paths below it can be compared.
+	if dest, err = filepath.EvalSymlinks(dest); err != nil {
+		return nil, err
+	}
+	x := &extractor{
+		ctx:    ctx,
+		dest:   dest,
+		layout: l,
+		prefix: splitPath(l.StripPrefix),
+		links:  map[string]bool{},
+		buf:    make([]byte, copyBufferSize),
+	}
+	if l.StripComponents < 0 {
+		return nil, fmt.Errorf("invalid number of path components to strip: %d", l.StripComponents)
+	}
+	if l.Dir != "" {
+		// Extract below the subdirectory, which must itself stay
+		// inside the destination.
+		dir := filepath.Join(dest, filepath.FromSlash(l.Dir))
+		if filepath.IsAbs(l.Dir) || path.IsAbs(l.Dir) || !x.contains(dir) || dir == dest {
+			return nil, fmt.Errorf("%s: illegal directory", l.Dir)
+		}
+		if err = x.parent(filepath.Join(dir, "x")); err != nil {
+			return nil, err
+		}
+		if x.dest, err = filepath.EvalSymlinks(dir); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// splitPath splits a slash separated path into its components, ignoring
+// empty and "." components.
+func splitPath(p string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(p), "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// strip applies the layout to an entry name. It reports false for
+// entries the layout removes entirely, e.g. the directories above the
+// stripped prefix.
+func (x *extractor) strip(name string) (string, bool) {
+	parts := splitPath(name)
+	if len(parts) < len(x.prefix) {
+		return "", false
+	}
+	for i, part := range x.prefix {
+		if parts[i] != part {
+			return "", false
+		}
+	}
+	parts = parts[len(x.prefix):]
+	if len(parts) <= x.layout.StripComponents {
+		return "", false
+	}
+	return path.Join(parts[x.layout.StripComponents:]...), true
+}
+
+// target returns the path an entry name is written to, or an empty path
+// if the layout skips the entry.
+func (x *extractor) target(name string) (string, error) {
+	rel, ok := x.strip(name)
+	if !ok {
+		return "", nil
+	}
+	p := filepath.Join(x.dest, filepath.FromSlash(rel))
+	if !x.contains(p) {
+		return "", fmt.Errorf("%s: illegal file path", name)
+	}
+	return p, nil
+}
+
+// entryTarget is like target but rejects the destination itself, which
+// only a directory entry may map to.
+func (x *extractor) entryTarget(name string) (string, error) {
+	p, err := x.target(name)
+	if err == nil && p == x.dest {
+		return "", fmt.Errorf("%s: illegal file path", name)
+	}
+	return p, err
+}
+
+func (x *extractor) contains(p string) bool {
+	return p == x.dest || strings.HasPrefix(p, x.dest+string(os.PathSeparator))
+}
+
+// parent creates the parent directory of p and checks that it does not
+// resolve outside the destination through a symlink written earlier.
+func (x *extractor) parent(p string) error {
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if !x.contains(resolved) {
+		return fmt.Errorf("%s: illegal file path", p)
+	}
+	return nil
+}
+
+func (x *extractor) dir(name string) error {
+	p, err := x.target(name)
+	if err != nil || p == "" || p == x.dest {
+		return err
+	}
+	if err = x.parent(p); err != nil {
+		return err
+	}
+	return os.MkdirAll(p, 0755)
+}
+
+func (x *extractor) file(name string, mode os.FileMode, r io.Reader) error {
+	p, err := x.entryTarget(name)
+	if err != nil || p == "" {
+		return err
+	}
+	if err = x.parent(p); err != nil {
+		return err
+	}
+	// Never write through an existing symlink or hard link: replace the
+	// entry instead of truncating the file it refers to.
+	if err = removeIfNotDir(p); err != nil {
+		return err
+	}
+
+	outFile, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return err
+	}
+	// Stream through a fixed size buffer so that memory use does not
+	// depend on the size of the file.
+	_, err = io.CopyBuffer(outFile, onlyReader{contextReader{x.ctx, r}}, x.buf)
+	// Close the file without defer to close before the next entry
+	if cerr := outFile.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	x.files = append(x.files, p)
+	return nil
+}
+
+// symlink creates a symlink whose target must stay inside the destination.
+// Absolute targets are rejected.
+func (x *extractor) symlink(name, linkname string) error {
+	p, err := x.entryTarget(name)
+	if err != nil || p == "" {
+		return err
+	}
+	if filepath.IsAbs(linkname) || path.IsAbs(linkname) {
+		return fmt.Errorf("%s: absolute symlink target %s", name, linkname)
+	}
+	if err = x.parent(p); err != nil {
+		return err
+	}
+	if _, err = x.resolveLink(p, linkname); err != nil {
+		return fmt.Errorf("%s: symlink target %s: %w", name, linkname, err)
+	}
+	// Replacing a directory or an earlier symlink would change where the
+	// symlinks already checked point to.
+	fi, err := os.Lstat(p)
+	switch {
+	case err == nil && (fi.IsDir() || x.links[p]):
+		return fmt.Errorf("%s: symlink replaces an earlier entry", name)
+	case err == nil:
+		if err = os.Remove(p); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	if err = os.Symlink(linkname, p); err != nil {
+		return err
+	}
+	x.links[p] = true
+	return nil
+}
+
+// errOutside is returned for links that resolve outside the destination.
+var errOutside = errors.New("outside the destination")
+
+// resolveLink returns the path a symlink at p with target linkname points
+// to, following the symlinks extracted so far like the operating system
+// would. Every step must stay inside the destination. A later entry may
+// create a missing component as a symlink, so no ".." may follow one.
+func (x *extractor) resolveLink(p, linkname string) (string, error) {
+	cur, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	exists := true
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if !exists {
+				return "", errOutside
+			}
+			cur = filepath.Dir(cur)
+		default:
+			cur = filepath.Join(cur, part)
+			if !exists {
+				break
+			}
+			resolved, err := filepath.EvalSymlinks(cur)
+			switch {
+			case os.IsNotExist(err):
+				exists = false
+			case err != nil:
+				return "", err
+			default:
+				cur = resolved
+			}
+		}
+		if !x.contains(cur) {
+			return "", errOutside
+		}
+	}
+	return cur, nil
+}
+
+// hardlink links name to a regular file extracted earlier.
+func (x *extractor) hardlink(name, linkname string) error {
+	p, err := x.entryTarget(name)
+	if err != nil || p == "" {
+		return err
+	}
+	target, err := x.target(linkname)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return fmt.Errorf("%s: hard link target %s is not extracted", name, linkname)
+	}
+	// os.Link follows symlinks in the target path, so check where it
+	// really is.
+	resolved, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return fmt.Errorf("%s: hard link target %s: %w", name, linkname, err)
+	}
+	if !x.contains(resolved) {
+		return fmt.Errorf("%s: hard link target %s is outside the destination", name, linkname)
+	}
+	fi, err := os.Lstat(resolved)
+	if err != nil {
+		return fmt.Errorf("%s: hard link target %s: %w", name, linkname, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s: hard link target %s is not a regular file", name, linkname)
+	}
+	if err = x.parent(p); err != nil {
+		return err
+	}
+	if err = removeIfNotDir(p); err != nil {
+		return err
+	}
+	if err = os.Link(resolved, p); err != nil {
+		return err
+	}
+	x.files = append(x.files, p)
+	return nil
+}
+
+// removeIfNotDir removes p unless it is a directory, so that a new file
+// can be created in its place.
+func removeIfNotDir(p string) error {
+	fi, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s: is a directory", p)
+	}
+	return os.Remove(p)
+}
+
+func extractTar(ctx context.Context, r io.Reader, base string, l layout) ([]string, error) {
+	x, err := newExtractor(ctx, base, l)
+	if err != nil {
+		return nil, err
+	}
+
+	tarReader := tar.NewReader(r)
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return x.files, err
+		}
+		header, err := tarReader.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return x.files, fmt.Errorf("extractTar: Next() failed: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(header.Name)
+		case tar.TypeReg:
+			err = x.file(header.Name, header.FileInfo().Mode(), tarReader)
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			err = x.hardlink(header.Name, header.Linkname)
+		case tar.TypeXGlobalHeader:
+			// PAX global headers carry no file
+		default:
+			err = fmt.Errorf(
+				"extractTar: unsupported type: %c in %s",
+				header.Typeflag,
+				header.Name)
+		}
+		if err != nil {
+			return x.files, err
+		}
+	}
+
+	return x.files, nil
+}
+
+// unzip extracts a zip archive into dest according to l and returns the paths of the
+// regular files written.
+func unzip(ctx context.Context, src io.ReaderAt, size int64, dest string, l layout) ([]string, error) {
+	r, err := zip.NewReader(src, size)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := newExtractor(ctx, dest, l)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		if err = ctx.Err(); err != nil {
+			return x.files, err
+		}
+		if err = unzipFile(x, f); err != nil {
+			return x.files, err
+		}
+	}
+
+	return x.files, nil
+}
+
+func unzipFile(x *extractor, f *zip.File) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return x.dir(f.Name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	switch {
+	case mode&os.ModeSymlink != 0:
+		// The content of a symlink entry is its target
+		linkname, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		return x.symlink(f.Name, string(linkname))
+	case mode.IsRegular():
+		return x.file(f.Name, mode, rc)
+	default:
+		return fmt.Errorf("unzip: unsupported file mode %s in %s", mode, f.Name)
+	}
+}
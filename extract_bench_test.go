@@ -0,0 +1,128 @@
+package standalone
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchEntrySize is the size of each file in the benchmark archives,
+// about that of a daprd binary. The contents are random so that the
+// archives are as large as what they hold.
+const (
+	benchEntries   = 4
+	benchEntrySize = 32 << 20
+)
+
+// BenchmarkExtractArchive extracts large zip and tar.gz archives from
+// files, which like embedded files can be read at any offset, and a zip
+// from a plain stream, which has to be read into memory. Compare B/op
+// with archive-MB: only the stream is buffered whole. maxrss-MB is the
+// peak RSS of the process, so run one sub-benchmark at a time, e.g.
+//
+//	go test -run '^$' -bench 'ExtractArchive/zip$' -benchtime 3x
+func BenchmarkExtractArchive(b *testing.B) {
+	dir := b.TempDir()
+	archives := map[string]string{
+		"zip":    filepath.Join(dir, "dapr.zip"),
+		"tar.gz": filepath.Join(dir, "dapr.tar.gz"),
+	}
+	writeBenchArchive(b, archives["zip"], func(w io.Writer) (func(name string) (io.Writer, error), func() error) {
+		zw := zip.NewWriter(w)
+		return func(name string) (io.Writer, error) {
+			return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		}, zw.Close
+	})
+	writeBenchArchive(b, archives["tar.gz"], func(w io.Writer) (func(name string) (io.Writer, error), func() error) {
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		return func(name string) (io.Writer, error) {
+				return tw, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: benchEntrySize})
+			}, func() error {
+				if err := tw.Close(); err != nil {
+					return err
+				}
+				return gw.Close()
+			}
+	})
+
+	tests := []struct {
+		name    string
+		archive string
+		// stream hides the random access of the file.
+		stream bool
+	}{
+		{"zip", archives["zip"], false},
+		{"zip stream", archives["zip"], true},
+		{"tar.gz", archives["tar.gz"], false},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			fi, err := os.Stat(tt.archive)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.SetBytes(fi.Size())
+			for n := 0; n < b.N; n++ {
+				f, err := os.Open(tt.archive)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var r io.Reader = f
+				if tt.stream {
+					r = struct{ io.Reader }{f}
+				}
+				dest := filepath.Join(b.TempDir(), "dest")
+				files, err := extractArchive(context.Background(), filepath.Base(tt.archive), r, dest, layout{})
+				f.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(files) != benchEntries {
+					b.Fatalf("extracted %d files, want %d", len(files), benchEntries)
+				}
+				b.StopTimer()
+				os.RemoveAll(dest)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(fi.Size())/(1<<20), "archive-MB")
+			if rss, ok := maxRSS(); ok {
+				b.ReportMetric(float64(rss)/(1<<20), "maxrss-MB")
+			}
+		})
+	}
+}
+
+// writeBenchArchive writes an archive of benchEntries random files to p
+// with the writer open returns: create starts an entry and close finishes
+// the archive.
+func writeBenchArchive(b *testing.B, p string, open func(w io.Writer) (create func(name string) (io.Writer, error), close func() error)) {
+	b.Helper()
+	f, err := os.Create(p)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	create, closeArchive := open(f)
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < benchEntries; n++ {
+		w, err := create(fmt.Sprintf("bin/daprd%d", n))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err = io.CopyN(w, rnd, benchEntrySize); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err = closeArchive(); err != nil {
+		b.Fatal(err)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.18
+// +build go1.18
+
+package standalone
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func FuzzExtractTar(f *testing.F) {
+	f.Add(tarArchive(f, fileEntry("bin/dapr", "dapr"), symlinkEntry("dapr", "bin/dapr")))
+	f.Add(tarArchive(f, symlinkEntry("p", "."), symlinkEntry("d", "p/p/p/../.."), hardlinkEntry("h", "d/victim"), fileEntry("h", "pwned")))
+	f.Add(tarArchive(f, fileEntry("f", "a"), hardlinkEntry("h", "f"), fileEntry("h", "b")))
+	f.Add(tarArchive(f, dirEntry("a"), symlinkEntry("d", "a/../a/.."), symlinkEntry("a", ".")))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		dest, check := sandbox(t)
+		extractTar(context.Background(), bytes.NewReader(b), dest, layout{})
+		check()
+	})
+}
+
+func FuzzUnzip(f *testing.F) {
+	f.Add(zipArchive(f, fileEntry("bin/dapr", "dapr"), symlinkEntry("dapr", "bin/dapr")))
+	f.Add(zipArchive(f, symlinkEntry("p", "."), symlinkEntry("d", "p/p/p/../.."), fileEntry("d/victim", "pwned")))
+	f.Add(zipArchive(f, symlinkEntry("d", ".."), fileEntry("d/victim", "pwned")))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		dest, check := sandbox(t)
+		unzip(context.Background(), bytes.NewReader(b), int64(len(b)), dest, layout{})
+		check()
+	})
+}
@@ -0,0 +1,455 @@
+package standalone
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// entry is an archive entry for the tests.
+type entry struct {
+	name string
+	// link is the target of a symlink or hard link.
+	link string
+	body string
+	typ  byte
+}
+
+func fileEntry(name, body string) entry { return entry{name: name, body: body, typ: tar.TypeReg} }
+func dirEntry(name string) entry        { return entry{name: name, typ: tar.TypeDir} }
+func symlinkEntry(name, link string) entry {
+	return entry{name: name, link: link, typ: tar.TypeSymlink}
+}
+func hardlinkEntry(name, link string) entry {
+	return entry{name: name, link: link, typ: tar.TypeLink}
+}
+
+func tarArchive(t testing.TB, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Linkname: e.link, Typeflag: e.typ, Mode: 0644, Size: int64(len(e.body))}
+		if e.typ == tar.TypeDir {
+			h.Mode = 0755
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t testing.TB, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		switch e.typ {
+		case tar.TypeDir:
+			h.Name += "/"
+			h.SetMode(fs.ModeDir | 0755)
+		case tar.TypeSymlink:
+			h.SetMode(fs.ModeSymlink | 0777)
+			body = e.link
+		default:
+			h.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sandbox returns a destination directory inside a root that also holds
+// a file named victim, and a function that fails the test if anything
+// outside the destination was created or changed.
+func sandbox(t testing.TB) (string, func()) {
+	t.Helper()
+	root := t.TempDir()
+	dest := filepath.Join(root, "a", "b", "dest")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{filepath.Join(root, "victim"), filepath.Join(root, "a", "victim")} {
+		if err := os.WriteFile(p, []byte("original"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dest, func() {
+		t.Helper()
+		checkOutside(t, root, dest)
+	}
+}
+
+func checkOutside(t testing.TB, root, dest string) {
+	t.Helper()
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch p {
+		case dest:
+			return filepath.SkipDir
+		case root, filepath.Join(root, "a"), filepath.Join(root, "a", "b"):
+			return nil
+		case filepath.Join(root, "victim"), filepath.Join(root, "a", "victim"):
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if string(b) != "original" {
+				t.Errorf("%s was overwritten with %q", p, b)
+			}
+			return nil
+		}
+		t.Errorf("%s was created outside the destination", p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractTarContained(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require Developer Mode")
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+		wantErr bool
+	}{
+		{
+			name: "symlink chain through a link to the directory",
+			entries: []entry{
+				symlinkEntry("p", "."),
+				symlinkEntry("d", "p/p/p/../.."),
+				hardlinkEntry("h", "d/victim"),
+				fileEntry("h", "pwned"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "dot dot after a missing component",
+			entries: []entry{symlinkEntry("d", "a/b/../../.."), symlinkEntry("a", ".")},
+			wantErr: true,
+		},
+		{
+			name:    "symlink replacing a directory",
+			entries: []entry{dirEntry("a"), symlinkEntry("d", "a/../a/.."), symlinkEntry("a", ".")},
+			wantErr: true,
+		},
+		{
+			name:    "symlink replacing an earlier symlink",
+			entries: []entry{dirEntry("x/y"), symlinkEntry("a", "x/y"), symlinkEntry("d", "a/../.."), symlinkEntry("a", ".")},
+			wantErr: true,
+		},
+		{
+			name:    "absolute symlink",
+			entries: []entry{symlinkEntry("d", "/etc")},
+			wantErr: true,
+		},
+		{
+			name:    "relative symlink outside",
+			entries: []entry{symlinkEntry("d", "../victim")},
+			wantErr: true,
+		},
+		{
+			name:    "file name outside",
+			entries: []entry{fileEntry("../victim", "pwned")},
+			wantErr: true,
+		},
+		{
+			name:    "file through a symlink inside",
+			entries: []entry{symlinkEntry("d", "."), fileEntry("d/../victim", "ok"), fileEntry("d/d/victim", "ok")},
+		},
+		{
+			name:    "hard link outside",
+			entries: []entry{hardlinkEntry("h", "../victim"), fileEntry("h", "pwned")},
+			wantErr: true,
+		},
+		{
+			name: "rewriting a hard linked file",
+			entries: []entry{
+				fileEntry("f", "original"),
+				hardlinkEntry("h", "f"),
+				fileEntry("h", "rewritten"),
+			},
+		},
+		{
+			name: "links inside",
+			entries: []entry{
+				dirEntry("lib"),
+				fileEntry("lib/dapr", "dapr"),
+				symlinkEntry("bin/dapr", "../lib/dapr"),
+				symlinkEntry("current", "lib/../lib"),
+				hardlinkEntry("bin/daprd", "current/dapr"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, check := sandbox(t)
+			_, err := extractTar(context.Background(), bytes.NewReader(tarArchive(t, tt.entries...)), dest, layout{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("extractTar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			check()
+		})
+	}
+}
+
+func TestExtractTarThroughExistingSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require Developer Mode")
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{"hard link", []entry{hardlinkEntry("h", "d/victim"), fileEntry("h", "pwned")}},
+		{"file", []entry{fileEntry("d/victim", "pwned")}},
+		{"symlink", []entry{symlinkEntry("l", "d/victim")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, check := sandbox(t)
+			// A link left behind in the destination, e.g. by an earlier
+			// extraction.
+			if err := os.Symlink("../../..", filepath.Join(dest, "d")); err != nil {
+				t.Fatal(err)
+			}
+			_, err := extractTar(context.Background(), bytes.NewReader(tarArchive(t, tt.entries...)), dest, layout{})
+			if err == nil {
+				t.Error("extractTar() succeeded, want an error")
+			}
+			os.Remove(filepath.Join(dest, "d"))
+			check()
+		})
+	}
+}
+
+func TestExtractTarHardLinkKeepsOtherLink(t *testing.T) {
+	dest, check := sandbox(t)
+	archive := tarArchive(t, fileEntry("f", "original"), hardlinkEntry("h", "f"), fileEntry("h", "rewritten"))
+	if _, err := extractTar(context.Background(), bytes.NewReader(archive), dest, layout{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"f": "original", "h": "rewritten"} {
+		b, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", name, b, want)
+		}
+	}
+	check()
+}
+
+func TestUnzipContained(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require Developer Mode")
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+		wantErr bool
+	}{
+		{
+			name:    "symlink chain",
+			entries: []entry{symlinkEntry("p", "."), symlinkEntry("d", "p/p/p/../.."), fileEntry("d/victim", "pwned")},
+			wantErr: true,
+		},
+		{
+			name:    "file name outside",
+			entries: []entry{fileEntry("../victim", "pwned")},
+			wantErr: true,
+		},
+		{
+			name:    "file through a symlink",
+			entries: []entry{symlinkEntry("d", ".."), fileEntry("d/victim", "pwned")},
+			wantErr: true,
+		},
+		{
+			name:    "files inside",
+			entries: []entry{dirEntry("bin"), fileEntry("bin/dapr", "dapr"), symlinkEntry("dapr", "bin/dapr")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, check := sandbox(t)
+			b := zipArchive(t, tt.entries...)
+			_, err := unzip(context.Background(), bytes.NewReader(b), int64(len(b)), dest, layout{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unzip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			check()
+		})
+	}
+}
+
+func TestExtractLayout(t *testing.T) {
+	entries := []entry{
+		dirEntry("dapr_1.6.0"),
+		fileEntry("dapr_1.6.0/README.md", "readme"),
+		dirEntry("dapr_1.6.0/release"),
+		dirEntry("dapr_1.6.0/release/linux_amd64"),
+		fileEntry("dapr_1.6.0/release/linux_amd64/daprd", "daprd"),
+		fileEntry("dapr_1.6.0/release/linux_amd64/web/index.html", "index"),
+		fileEntry("dapr_1.6.0/release/windows_amd64/daprd.exe", "daprd.exe"),
+		fileEntry("LICENSE", "license"),
+	}
+	tests := []struct {
+		name    string
+		layout  layout
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "as is",
+			want: []string{
+				"dapr_1.6.0/README.md",
+				"dapr_1.6.0/release/linux_amd64/daprd",
+				"dapr_1.6.0/release/linux_amd64/web/index.html",
+				"dapr_1.6.0/release/windows_amd64/daprd.exe",
+				"LICENSE",
+			},
+		},
+		{
+			name:   "strip components",
+			layout: layout{StripComponents: 1},
+			want: []string{
+				"README.md",
+				"release/linux_amd64/daprd",
+				"release/linux_amd64/web/index.html",
+				"release/windows_amd64/daprd.exe",
+			},
+		},
+		{
+			name:   "strip more components than some entries have",
+			layout: layout{StripComponents: 3},
+			want:   []string{"daprd", "web/index.html", "daprd.exe"},
+		},
+		{
+			name:   "strip prefix",
+			layout: layout{StripPrefix: "dapr_1.6.0/release/linux_amd64"},
+			want:   []string{"daprd", "web/index.html"},
+		},
+		{
+			name:   "strip prefix with slashes",
+			layout: layout{StripPrefix: "/dapr_1.6.0//release/linux_amd64/"},
+			want:   []string{"daprd", "web/index.html"},
+		},
+		{
+			name:   "strip prefix then components",
+			layout: layout{StripPrefix: "dapr_1.6.0/release", StripComponents: 1},
+			want:   []string{"daprd", "web/index.html", "daprd.exe"},
+		},
+		{
+			name:   "into a directory",
+			layout: layout{StripPrefix: "dapr_1.6.0/release/linux_amd64", Dir: "dashboard"},
+			want:   []string{"dashboard/daprd", "dashboard/web/index.html"},
+		},
+		{
+			name:   "into a nested directory",
+			layout: layout{StripComponents: 4, Dir: "web/dashboard"},
+			want:   []string{"web/dashboard/index.html"},
+		},
+		{
+			name:   "prefix of no entry",
+			layout: layout{StripPrefix: "dapr_1.5.1"},
+		},
+		{
+			name:    "negative components",
+			layout:  layout{StripComponents: -1},
+			wantErr: true,
+		},
+		{
+			name:    "directory outside",
+			layout:  layout{Dir: "../dashboard"},
+			wantErr: true,
+		},
+		{
+			name:    "absolute directory",
+			layout:  layout{Dir: "/dashboard"},
+			wantErr: true,
+		},
+		{
+			name:    "destination as directory",
+			layout:  layout{Dir: "."},
+			wantErr: true,
+		},
+	}
+	formats := []struct {
+		name    string
+		extract func(t *testing.T, dest string, l layout) ([]string, error)
+	}{
+		{"tar", func(t *testing.T, dest string, l layout) ([]string, error) {
+			return extractTar(context.Background(), bytes.NewReader(tarArchive(t, entries...)), dest, l)
+		}},
+		{"zip", func(t *testing.T, dest string, l layout) ([]string, error) {
+			b := zipArchive(t, entries...)
+			return unzip(context.Background(), bytes.NewReader(b), int64(len(b)), dest, l)
+		}},
+	}
+	for _, f := range formats {
+		for _, tt := range tests {
+			t.Run(f.name+"/"+tt.name, func(t *testing.T) {
+				dest, check := sandbox(t)
+				files, err := f.extract(t, dest, tt.layout)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+				}
+				check()
+
+				var got []string
+				for _, p := range files {
+					rel, err := filepath.Rel(dest, p)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, filepath.ToSlash(rel))
+				}
+				if !sameStrings(got, tt.want) {
+					t.Errorf("extracted %v, want %v", got, tt.want)
+				}
+				// Only the returned files are written.
+				var written []string
+				filepath.WalkDir(dest, func(p string, d fs.DirEntry, err error) error {
+					if err == nil && !d.IsDir() {
+						rel, _ := filepath.Rel(dest, p)
+						written = append(written, filepath.ToSlash(rel))
+					}
+					return err
+				})
+				if !sameStrings(written, tt.want) {
+					t.Errorf("wrote %v, want %v", written, tt.want)
+				}
+			})
+		}
+	}
+}
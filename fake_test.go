@@ -0,0 +1,249 @@
+package standalone
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// fakeRuntime is a ContainerRuntime that keeps its containers, images and
+// networks in memory.
+type fakeRuntime struct {
+	containers map[string]ContainerInfo
+	// images maps the image names to their IDs.
+	images   map[string]string
+	networks map[string]bool
+	// loads are the images returned by the next calls to Load, in order.
+	loads []string
+	// fail makes a call fail, keyed by the method and its first argument,
+	// e.g. "Run dapr_redis".
+	fail map[string]error
+	// calls records every call that changes state.
+	calls []string
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		containers: map[string]ContainerInfo{},
+		images:     map[string]string{},
+		networks:   map[string]bool{},
+		fail:       map[string]error{},
+	}
+}
+
+// queueLoads makes the next calls to Load return the test images of
+// version for services.
+func (f *fakeRuntime) queueLoads(version string, services ...Service) {
+	for _, s := range services {
+		f.loads = append(f.loads, testImage(s, version))
+	}
+}
+
+func (f *fakeRuntime) call(method, arg string) error {
+	call := method + " " + arg
+	f.calls = append(f.calls, call)
+	return f.fail[call]
+}
+
+func (f *fakeRuntime) Name() string { return "fake" }
+
+func (f *fakeRuntime) Load(ctx context.Context, in io.Reader, out io.Writer) ([]string, error) {
+	if len(f.loads) == 0 {
+		return nil, fmt.Errorf("unexpected load")
+	}
+	image := f.loads[0]
+	f.loads = f.loads[1:]
+	if err := f.call("Load", image); err != nil {
+		return nil, err
+	}
+	f.images[image] = "sha256:" + image
+	fmt.Fprintf(out, "Loaded image: %s\n", image)
+	return []string{image}, nil
+}
+
+func (f *fakeRuntime) Images(ctx context.Context) (map[string]bool, error) {
+	images := map[string]bool{}
+	for image := range f.images {
+		images[image] = true
+	}
+	return images, nil
+}
+
+func (f *fakeRuntime) RemoveImage(ctx context.Context, image string) error {
+	if err := f.call("RemoveImage", image); err != nil {
+		return err
+	}
+	delete(f.images, image)
+	return nil
+}
+
+func (f *fakeRuntime) ImageID(ctx context.Context, image string) (string, error) {
+	id, ok := f.images[image]
+	if !ok {
+		return "", fmt.Errorf("no such image: %s", image)
+	}
+	return id, nil
+}
+
+func (f *fakeRuntime) Run(ctx context.Context, spec ContainerSpec) error {
+	if err := f.call("Run", spec.Name); err != nil {
+		return err
+	}
+	if f.containers[spec.Name].Exists {
+		return fmt.Errorf("%w: %s", ErrNameConflict, spec.Name)
+	}
+	f.containers[spec.Name] = ContainerInfo{Exists: true, Running: true, Image: spec.Image, Ports: spec.Ports}
+	return nil
+}
+
+func (f *fakeRuntime) Start(ctx context.Context, name string) error {
+	return f.set(name, "Start", func(c *ContainerInfo) { c.Running = true })
+}
+
+func (f *fakeRuntime) Stop(ctx context.Context, name string) error {
+	return f.set(name, "Stop", func(c *ContainerInfo) { c.Running = false })
+}
+
+func (f *fakeRuntime) set(name, method string, fn func(c *ContainerInfo)) error {
+	if err := f.call(method, name); err != nil {
+		return err
+	}
+	c, ok := f.containers[name]
+	if !ok {
+		return fmt.Errorf("no such container: %s", name)
+	}
+	fn(&c)
+	f.containers[name] = c
+	return nil
+}
+
+func (f *fakeRuntime) Rename(ctx context.Context, name, newName string) error {
+	if err := f.call("Rename", name); err != nil {
+		return err
+	}
+	c, ok := f.containers[name]
+	if !ok {
+		return fmt.Errorf("no such container: %s", name)
+	}
+	delete(f.containers, name)
+	f.containers[newName] = c
+	return nil
+}
+
+func (f *fakeRuntime) Remove(ctx context.Context, name string) error {
+	if err := f.call("Remove", name); err != nil {
+		return err
+	}
+	delete(f.containers, name)
+	return nil
+}
+
+func (f *fakeRuntime) Inspect(ctx context.Context, name string) (ContainerInfo, error) {
+	return f.containers[name], nil
+}
+
+func (f *fakeRuntime) NetworkExists(ctx context.Context, name string) (bool, error) {
+	return f.networks[name], nil
+}
+
+func (f *fakeRuntime) CreateNetwork(ctx context.Context, name string) error {
+	if err := f.call("CreateNetwork", name); err != nil {
+		return err
+	}
+	f.networks[name] = true
+	return nil
+}
+
+func (f *fakeRuntime) RemoveNetwork(ctx context.Context, name string) error {
+	if err := f.call("RemoveNetwork", name); err != nil {
+		return err
+	}
+	delete(f.networks, name)
+	return nil
+}
+
+// newTestInstaller returns an Installer that uses rt and discards its
+// progress events.
+func newTestInstaller(t testing.TB, rt *fakeRuntime, opts Options) *Installer {
+	t.Helper()
+	opts.ContainerRuntime = rt
+	if opts.Observer == nil {
+		opts.Observer = ObserverFunc(func(Event) {})
+	}
+	i, err := NewInstaller(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// useTestBundle replaces the embedded release manifest for the test with
+// one that bundles versions, the first being the default. Every release
+// uses the embedded CLI, binaries and image archives, and images named
+// "<service>:<version>".
+func useTestBundle(t testing.TB, versions ...string) {
+	t.Helper()
+	m := bundleManifest{Default: versions[0]}
+	for _, v := range versions {
+		b := bundle{Version: v}
+		for _, dir := range []string{"cli", "binaries"} {
+			p := firstFile(t, assets, dir)
+			b.Assets = append(b.Assets, bundleAsset{Path: p, SHA256: embeddedSum(t, assets, p)})
+		}
+		p := firstFile(t, imageArchives, "images")
+		for _, s := range AllServices {
+			b.Images = append(b.Images, bundleImage{
+				Role:   s,
+				Image:  testImage(s, v),
+				File:   strings.TrimPrefix(p, "images/"),
+				SHA256: embeddedSum(t, imageArchives, p),
+			})
+		}
+		m.Releases = append(m.Releases, b)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, savedSig := bundleJSON, bundleSignature
+	bundleJSON, bundleSignature = b, nil
+	t.Cleanup(func() { bundleJSON, bundleSignature = saved, savedSig })
+}
+
+func testImage(s Service, version string) string {
+	return string(s) + ":" + version
+}
+
+func firstFile(t testing.TB, fsys fs.FS, dir string) string {
+	t.Helper()
+	var first string
+	err := fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || first != "" {
+			return err
+		}
+		if !d.IsDir() {
+			first = p
+		}
+		return nil
+	})
+	if err != nil || first == "" {
+		t.Fatalf("no embedded file in %s: %v", dir, err)
+	}
+	return first
+}
+
+func embeddedSum(t testing.TB, fsys fs.FS, p string) string {
+	t.Helper()
+	b, err := fs.ReadFile(fsys, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
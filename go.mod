@@ -2,4 +2,9 @@ module github.com/dapr/standalone
 
 go 1.17
 
-require gopkg.in/yaml.v2 v2.4.0
+require (
+	github.com/klauspost/compress v1.15.15
+	github.com/ulikunitz/xz v0.5.11
+	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,178 @@
+package standalone
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultReadyTimeout = 60 * time.Second
+
+// readyPollInterval is how often a service is checked while waiting for it.
+var readyPollInterval = 500 * time.Millisecond
+
+// ErrCrashLoop is returned when a service container keeps being restarted
+// by its restart policy instead of becoming ready.
+var ErrCrashLoop = errors.New("container is crash looping")
+
+// NotReadyError is returned when a service does not become ready after
+// its container was started.
+type NotReadyError struct {
+	Service Service
+	Err     error
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("%s is not ready: %v", e.Service, e.Err)
+}
+
+func (e *NotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// probe checks whether a service accepts connections at addr.
+type probe func(ctx context.Context, addr string) error
+
+var serviceProbes = map[Service]probe{
+	ServicePlacement: probeTCP,
+	ServiceRedis:     probeRedis,
+	ServiceZipkin:    probeZipkin,
+}
+
+var serviceContainers = map[Service]string{
+	ServicePlacement: DaprPlacementContainerName,
+	ServiceRedis:     DaprRedisContainerName,
+	ServiceZipkin:    DaprZipkinContainerName,
+}
+
+// waitForServices waits until every started service is ready.
+func (i *Installer) waitForServices(ctx context.Context) error {
+	i.step("Waiting for services to be ready...")
+	for _, s := range AllServices {
+		if !i.hasService(s) {
+			continue
+		}
+		i.emit(Event{Kind: EventServiceWaiting, Message: string(s), Service: s})
+		start := time.Now()
+		if err := i.waitReady(ctx, s); err != nil {
+			i.emit(Event{Kind: EventServiceFailed, Service: s, Duration: time.Since(start), Error: err.Error()})
+			return &NotReadyError{Service: s, Err: err}
+		}
+		i.emit(Event{Kind: EventServiceReady, Service: s, Duration: time.Since(start)})
+	}
+	return nil
+}
+
+// waitReady polls the container of s until it is running and, when its
+// port is published on the host, its probe succeeds.
+func (i *Installer) waitReady(ctx context.Context, s Service) error {
+	ctx, cancel := context.WithTimeout(ctx, i.opts.ReadyTimeout)
+	defer cancel()
+
+	container := createContainerName(serviceContainers[s], i.opts.Network)
+	var check probe
+	if i.opts.Network == "" {
+		// On a network the port is not reachable from the host, so only
+		// the container state is checked.
+		check = serviceProbes[s]
+	}
+	host, port := i.serviceAddress(s)
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+
+	restartCount := -1
+	var lastErr error
+	for {
+		info, err := i.rt.Inspect(ctx, container)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !info.Exists:
+			return fmt.Errorf("container %s no longer exists", container)
+		case restartCount >= 0 && info.RestartCount > restartCount:
+			return fmt.Errorf("%w: %s was restarted %d times, check `%s logs %s`",
+				ErrCrashLoop, container, info.RestartCount, i.rt.Name(), container)
+		case info.Running && check == nil:
+			return nil
+		case info.Running:
+			if lastErr = check(ctx, addr); lastErr == nil {
+				return nil
+			}
+		case info.Restarting:
+			lastErr = fmt.Errorf("container %s is restarting", container)
+		default:
+			lastErr = fmt.Errorf("container %s is not running", container)
+		}
+		if restartCount < 0 {
+			restartCount = info.RestartCount
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return ctx.Err()
+			}
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+			return fmt.Errorf("timed out after %s: %v", i.opts.ReadyTimeout, lastErr)
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+func probeTCP(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeRedis sends a PING and expects a PONG.
+func probeRedis(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err = conn.Write([]byte("*1\r\n$4\r\nPING\r\n")); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if reply = strings.TrimSpace(reply); reply != "+PONG" {
+		return fmt.Errorf("unexpected reply to PING: %s", reply)
+	}
+	return nil
+}
+
+// probeZipkin expects the health endpoint to return 200 OK.
+func probeZipkin(ctx context.Context, addr string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inspectingRuntime lets a test change what Inspect returns on each call.
+type inspectingRuntime struct {
+	*fakeRuntime
+	inspect func(info ContainerInfo) ContainerInfo
+}
+
+func (r inspectingRuntime) Inspect(ctx context.Context, name string) (ContainerInfo, error) {
+	info, err := r.fakeRuntime.Inspect(ctx, name)
+	if err != nil || !info.Exists {
+		return info, err
+	}
+	info = r.inspect(info)
+	r.containers[name] = info
+	return info, nil
+}
+
+func TestWaitForServices(t *testing.T) {
+	saved := readyPollInterval
+	readyPollInterval = time.Millisecond
+	defer func() { readyPollInterval = saved }()
+
+	tests := []struct {
+		name string
+		// probeFailures is how often the probe fails before it succeeds,
+		// -1 for always.
+		probeFailures int
+		inspect       func(info ContainerInfo) ContainerInfo
+		wantErr       error
+		// wantMsg is contained in the error.
+		wantMsg string
+	}{
+		{name: "ready at once"},
+		{name: "ready after probes", probeFailures: 3},
+		{
+			name: "ready once running",
+			inspect: func(info ContainerInfo) ContainerInfo {
+				// Not running yet on the first inspection.
+				info.Running = info.Image == "started"
+				info.Image = "started"
+				return info
+			},
+		},
+		{name: "timeout", probeFailures: -1, wantMsg: "timed out after 50ms: connection refused"},
+		{
+			name: "crash loop",
+			inspect: func(info ContainerInfo) ContainerInfo {
+				info.Running, info.Restarting = false, true
+				info.RestartCount++
+				return info
+			},
+			wantErr: ErrCrashLoop,
+			wantMsg: "dapr_redis was restarted 3 times, check `fake logs dapr_redis`",
+		},
+		{
+			name: "removed",
+			inspect: func(info ContainerInfo) ContainerInfo {
+				return ContainerInfo{}
+			},
+			wantMsg: "container dapr_redis no longer exists",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := 0
+			savedProbes := serviceProbes
+			serviceProbes = map[Service]probe{ServiceRedis: func(ctx context.Context, addr string) error {
+				probes++
+				if tt.probeFailures < 0 || probes <= tt.probeFailures {
+					return errors.New("connection refused")
+				}
+				return nil
+			}}
+			defer func() { serviceProbes = savedProbes }()
+
+			fake := newFakeRuntime()
+			fake.containers[DaprRedisContainerName] = ContainerInfo{Exists: true, Running: true, RestartCount: 1}
+			var rt ContainerRuntime = fake
+			if tt.inspect != nil {
+				rt = inspectingRuntime{fake, tt.inspect}
+			}
+			var events []Event
+			i, err := NewInstaller(Options{
+				InstallDir:       t.TempDir(),
+				Services:         []Service{ServiceRedis},
+				ContainerRuntime: rt,
+				ReadyTimeout:     50 * time.Millisecond,
+				Observer:         ObserverFunc(func(e Event) { events = append(events, e) }),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = i.waitForServices(context.Background())
+			last := events[len(events)-1]
+			if tt.wantMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if last.Kind != EventServiceReady || last.Service != ServiceRedis {
+					t.Errorf("last event = %+v, want redis ready", last)
+				}
+				if tt.probeFailures > 0 && probes != tt.probeFailures+1 {
+					t.Errorf("probed %d times, want %d", probes, tt.probeFailures+1)
+				}
+				return
+			}
+
+			var notReady *NotReadyError
+			if !errors.As(err, &notReady) || notReady.Service != ServiceRedis {
+				t.Fatalf("waitForServices() error = %v, want a NotReadyError for redis", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("waitForServices() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("error %q does not contain %q", err, tt.wantMsg)
+			}
+			if last.Kind != EventServiceFailed || last.Error == "" {
+				t.Errorf("last event = %+v, want redis failed", last)
+			}
+		})
+	}
+}
+
+func TestWaitForServicesCrashLoopFailsFast(t *testing.T) {
+	fake := newFakeRuntime()
+	fake.containers[DaprRedisContainerName] = ContainerInfo{Exists: true, Restarting: true}
+	rt := inspectingRuntime{fake, func(info ContainerInfo) ContainerInfo {
+		info.RestartCount++
+		return info
+	}}
+	i := newTestInstaller(t, fake, Options{InstallDir: t.TempDir(), Services: []Service{ServiceRedis}, ReadyTimeout: time.Minute})
+	i.rt = rt
+
+	start := time.Now()
+	err := i.waitForServices(context.Background())
+	if !errors.Is(err, ErrCrashLoop) {
+		t.Fatalf("waitForServices() error = %v, want %v", err, ErrCrashLoop)
+	}
+	// It gives up on the first restart, long before the timeout.
+	if elapsed := time.Since(start); elapsed > 10*readyPollInterval {
+		t.Errorf("gave up after %s", elapsed)
+	}
+}
@@ -1,15 +1,13 @@
 package standalone
 
 import (
-	"archive/tar"
-	"archive/zip"
 	"bytes"
-	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
-	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -17,13 +15,13 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 const (
-	daprDefaultHost     = "localhost"
-	daprDockerImageName = "daprio/dapr"
+	daprDefaultHost = "localhost"
 
 	// DaprPlacementContainerName is the container name of placement service.
 	DaprPlacementContainerName = "dapr_placement"
@@ -31,281 +29,829 @@ const (
 	DaprRedisContainerName = "dapr_redis"
 	// DaprZipkinContainerName is the container name of zipkin.
 	DaprZipkinContainerName = "dapr_zipkin"
+
+	placementContainerPort = 50005
+	redisContainerPort     = 6379
+	zipkinContainerPort    = 9411
+
+	defaultStepTimeout = 10 * time.Minute
+
+	// cleanupTimeout bounds the rollback or commit of an install, which
+	// runs even if the install was canceled.
+	cleanupTimeout = 2 * time.Minute
 )
 
 //go:embed images
-var images embed.FS
+var imageArchives embed.FS
 
 var osarch = fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH)
 
+// Installer installs a Dapr release for local development.
+type Installer struct {
+	opts Options
+
+	binDir     string
+	compDir    string
+	configPath string
+
+	rt    ContainerRuntime
+	rtErr error
+
+	bundle *bundle
+
+	manifest *Manifest
+	// previous is the manifest of the install being replaced, if any.
+	previous *Manifest
+
+	// upgrading replaces containers that run an image other than the
+	// bundled one, which Install otherwise keeps.
+	upgrading bool
+}
+
+// NewInstaller returns an Installer for opts, filling in defaults for
+// any fields left unset.
+func NewInstaller(opts Options) (*Installer, error) {
+	if err := opts.setDefaults(); err != nil {
+		return nil, err
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	i := &Installer{
+		opts:       opts,
+		binDir:     filepath.Join(opts.InstallDir, "bin"),
+		compDir:    filepath.Join(opts.InstallDir, "components"),
+		configPath: filepath.Join(opts.InstallDir, "config.yaml"),
+		rt:         opts.ContainerRuntime,
+	}
+	if i.rt == nil {
+		// Not every operation needs a container runtime, so only fail
+		// once one is used.
+		i.rt, i.rtErr = DetectContainerRuntime()
+	}
+	return i, nil
+}
+
+// ContainerRuntime returns the container runtime used by the installer.
+func (i *Installer) ContainerRuntime() (ContainerRuntime, error) {
+	return i.rt, i.rtErr
+}
+
+// Install installs the given Dapr version using the default options.
 func Install(version string) error {
-	fmt.Printf("Installing Dapr %s\n", version)
-	homedir, err := os.UserHomeDir()
+	installer, err := NewInstaller(Options{Version: version})
 	if err != nil {
 		return err
 	}
+	return installer.Install(context.Background())
+}
+
+// Install extracts the CLI and binaries, writes the default configuration
+// and components, loads the bundled images and starts the service containers.
+//
+// The install is transactional. The binaries are staged next to the bin
+// directory and swapped in once everything else succeeded, and any failure
+// rolls back the files, images and containers to their previous state.
+// A manifest of everything created is written to the install directory.
+func (i *Installer) Install(ctx context.Context) (err error) {
+	if err = i.selectVersion(); err != nil {
+		return err
+	}
+
+	i.step("Installing Dapr %s", i.opts.Version)
 
-	versionNum := strings.TrimPrefix(version, "v")
+	if i.rtErr != nil {
+		return i.rtErr
+	}
 
-	daprHomeDir := filepath.Join(homedir, ".dapr")
-	daprCompDir := filepath.Join(daprHomeDir, "components")
-	if err = os.MkdirAll(daprCompDir, 0775); err != nil {
+	if err = i.prepare(ctx); err != nil {
 		return err
 	}
-	daprBinDir := filepath.Join(daprHomeDir, "bin")
-	if err = os.MkdirAll(daprBinDir, 0775); err != nil {
+	if err = i.preflightPorts(ctx); err != nil {
 		return err
 	}
 
-	fmt.Println("Installing CLI...")
-	if runtime.GOOS == "windows" {
-		_, err = unzip(bytes.NewReader(cliBinary), int64(len(cliBinary)), daprBinDir)
-	} else {
-		err = extractTarGz(bytes.NewReader(cliBinary), daprBinDir)
+	if err = os.MkdirAll(i.compDir, 0775); err != nil {
+		return err
 	}
-	if err != nil {
-		return fmt.Errorf("could not install CLI: %w", err)
+
+	if i.previous, err = ReadManifest(i.opts.InstallDir); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	daprExeName := "dapr"
-	if runtime.GOOS == "windows" {
-		daprExeName += ".exe"
+	i.manifest = &Manifest{
+		Version:     i.opts.Version,
+		InstalledAt: time.Now().UTC(),
+		Network:     i.opts.Network,
+	}
+	if i.opts.Network == "" {
+		i.manifest.Ports = map[Service]int{}
+		for _, s := range i.opts.Services {
+			_, i.manifest.Ports[s] = i.serviceAddress(s)
+		}
 	}
+	tx := &transaction{i: i}
+	defer func() {
+		// Clean up even if ctx was canceled, e.g. by Ctrl-C.
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		if err == nil {
+			tx.commit(cleanupCtx)
+		} else if rerr := tx.rollback(cleanupCtx); rerr != nil {
+			err = fmt.Errorf("%w (%v)", err, rerr)
+		} else {
+			// The previous installation and its manifest are intact.
+			return
+		}
+		i.manifest.Complete = err == nil
+		if err != nil {
+			i.manifest.Error = err.Error()
+		}
+		if werr := i.manifest.write(i.opts.InstallDir); werr != nil && err == nil {
+			err = fmt.Errorf("could not write install manifest: %w", werr)
+		}
+		if err == nil {
+			// Kept with the version so that Use can switch back to it.
+			if werr := i.manifest.write(i.versionDir(i.opts.Version)); werr != nil {
+				err = fmt.Errorf("could not write install manifest: %w", werr)
+			}
+		}
+	}()
 
-	configPath := filepath.Join(daprHomeDir, "config.yaml")
-	if err = createDefaultConfiguration(daprDefaultHost, configPath); err != nil {
+	stagingDir, err := ioutil.TempDir(i.opts.InstallDir, ".bin-staging-")
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(stagingDir, 0775); err != nil {
 		return err
 	}
-	if err = createRedisPubSub(daprDefaultHost, daprCompDir); err != nil {
+	// The extracted paths are absolute with symlinks resolved.
+	if stagingDir, err = filepath.Abs(stagingDir); err != nil {
 		return err
 	}
-	if err = createRedisStateStore(daprDefaultHost, daprCompDir); err != nil {
+	if stagingDir, err = filepath.EvalSymlinks(stagingDir); err != nil {
 		return err
 	}
+	// Once swapped in, the staging directory no longer exists.
+	defer os.RemoveAll(stagingDir)
 
-	fmt.Println("Installing binaries...")
-	// The embed package does not use path separators of the OS.
-	// Using filepath.Join does not work.
-	// https://github.com/golang/go/issues/44305
-	dir := path.Join("binaries", osarch)
-	entries, err := binaries.ReadDir(dir)
+	i.step("Installing CLI...")
+	var files []string
+	cliFiles, err := i.installCLI(ctx, stagingDir)
 	if err != nil {
-		return fmt.Errorf("could not binary root for %s: %w", osarch, err)
+		return err
+	}
+	files = append(files, cliFiles...)
+	daprExeName := "dapr"
+	if runtime.GOOS == "windows" {
+		daprExeName += ".exe"
 	}
-	for _, e := range entries {
-		fmt.Printf("  • %s\n", e.Name())
-		f, err := binaries.Open(path.Join(dir, e.Name()))
-		if err != nil {
-			return fmt.Errorf("could not open file %s: %w", e.Name(), err)
-		}
 
-		ext := strings.ToLower(filepath.Ext(e.Name()))
-		switch ext {
-		case ".zip":
-			var fi fs.FileInfo
-			fi, err = f.Stat()
-			if err != nil {
-				return err
-			}
-			var fileBytes []byte
-			fileBytes, err = io.ReadAll(f)
-			if err == nil {
-				_, err = unzip(bytes.NewReader(fileBytes), fi.Size(), daprBinDir)
-			}
-		case ".tar.gz", ".gz":
-			err = extractTarGz(f, daprBinDir)
-		default:
-			fmt.Printf("Unknown ext: %s\n", ext)
-		}
-		if err != nil {
-			return err
-		}
-		f.Close()
+	if err = i.writeConfiguration(tx); err != nil {
+		return err
 	}
 
-	fmt.Println("Loading docker images...")
-	entries, err = images.ReadDir("images")
+	i.step("Installing binaries...")
+	binFiles, err := i.installBinaries(ctx, stagingDir)
 	if err != nil {
 		return err
 	}
-	for _, e := range entries {
-		fmt.Printf("  • %s... ", e.Name())
-		f, err := images.Open(path.Join("images", e.Name()))
-		if err != nil {
-			return err
-		}
+	files = append(files, binFiles...)
 
-		if err := dockerLoad(f); err != nil {
+	i.step("Loading %s images...", i.rt.Name())
+	if err = i.loadImages(ctx, tx); err != nil {
+		return err
+	}
+
+	err = i.withStepTimeout(ctx, func(ctx context.Context) error {
+		if err := i.requireImages(ctx); err != nil {
 			return err
 		}
-		f.Close()
+		return i.startServices(ctx, tx)
+	})
+	if err != nil {
+		return err
 	}
-
-	dockerNetwork := ""
-
-	if err := removeDockerContainer(DaprPlacementContainerName, dockerNetwork); err != nil {
-		return fmt.Errorf("could not stop previously installed placement service: %w", err)
+	if err = i.waitForServices(ctx); err != nil {
+		return err
 	}
 
-	fmt.Println("Starting docker containers...")
-	fmt.Println("  • Dapr placement service")
-	if err := runPlacementService(versionNum, dockerNetwork); err != nil {
-		return fmt.Errorf("could not start placement service: %w", err)
+	if err = ctx.Err(); err != nil {
+		return err
 	}
-	fmt.Println("  • redis")
-	if err := runRedis(dockerNetwork); err != nil {
-		return fmt.Errorf("could not start redis: %w", err)
+	versionBinDir := filepath.Join(i.versionDir(i.opts.Version), "bin")
+	if err = os.MkdirAll(filepath.Dir(versionBinDir), 0775); err != nil {
+		return err
 	}
-	fmt.Println("  • openzipkin/zipkin")
-	if err := runZipkin(dockerNetwork); err != nil {
-		return fmt.Errorf("could not start zipkin: %w", err)
+	backupDir, err := swapDir(stagingDir, versionBinDir)
+	if err != nil {
+		return fmt.Errorf("could not replace %s: %w", versionBinDir, err)
+	}
+	tx.onRollback("restore "+versionBinDir, func(context.Context) error {
+		return restoreDir(versionBinDir, backupDir)
+	})
+	if backupDir != "" {
+		tx.onCommit("remove "+backupDir, func(context.Context) error {
+			return os.RemoveAll(backupDir)
+		})
+	}
+	for idx, f := range files {
+		files[idx] = filepath.Join(versionBinDir, strings.TrimPrefix(f, stagingDir))
+	}
+	if err = i.manifest.addFiles(i.opts.InstallDir, files...); err != nil {
+		return err
+	}
+	if err = i.setCurrent(tx, i.opts.Version); err != nil {
+		return fmt.Errorf("could not switch to %s: %w", i.opts.Version, err)
 	}
 
-	fmt.Println()
-	fmt.Println("Success!")
-	fmt.Printf("The Dapr CLI was installed to %s/%s.\n", daprBinDir, daprExeName)
-	fmt.Printf("You may want to add %s to your PATH or copy %s to a PATH location.\n", daprBinDir, daprExeName)
+	lines := []string{
+		"Success!",
+		fmt.Sprintf("The Dapr CLI was installed to %s/%s.", i.binDir, daprExeName),
+		fmt.Sprintf("You may want to add %s to your PATH or copy %s to a PATH location.", i.binDir, daprExeName),
+	}
 	if runtime.GOOS != "windows" {
-		fmt.Printf("e.g. > sudo cp %s/%s /usr/local/bin\n", daprBinDir, daprExeName)
+		lines = append(lines, fmt.Sprintf("e.g. > sudo cp %s/%s /usr/local/bin", i.binDir, daprExeName))
+	}
+	lines = append(lines, fmt.Sprintf("Run daprd with --components-path %s --config %s", i.compDir, i.configPath))
+	if i.opts.Network != "" && i.hasService(ServicePlacement) {
+		host, port := i.serviceAddress(ServicePlacement)
+		lines = append(lines,
+			fmt.Sprintf("The containers are attached to the %s network.", i.opts.Network),
+			fmt.Sprintf("Run daprd on the same network with --placement-host-address %s:%d.", host, port))
 	}
-	fmt.Println()
+	i.emit(Event{Kind: EventDone, Message: strings.Join(lines, "\n"), Name: filepath.Join(i.binDir, daprExeName)})
 
 	return nil
 }
 
-func dockerLoad(in io.Reader) error {
-	subProcess := exec.Command("docker", "load")
-
-	stdin, err := subProcess.StdinPipe()
+// prepare loads the release manifest and checks it and the embedded
+// assets before anything is installed.
+func (i *Installer) prepare(ctx context.Context) error {
+	err := i.verifyBundleSignature()
 	if err != nil {
-		return fmt.Errorf("an error occured: %w", err)
+		return fmt.Errorf("refusing to install: %w", err)
+	}
+	if i.bundle, err = loadBundle(i.opts.Version); err != nil {
+		return err
+	}
+	if err = i.verifyAssets(ctx); err != nil {
+		return fmt.Errorf("refusing to install: %w", err)
 	}
-	defer stdin.Close()
+	return nil
+}
 
-	subProcess.Stdout = os.Stdout
-	subProcess.Stderr = os.Stderr
+// selectVersion checks that Options.Version is bundled, defaulting it to
+// the default bundled version.
+func (i *Installer) selectVersion() error {
+	b, err := loadBundle(i.opts.Version)
+	if err != nil {
+		return err
+	}
+	i.opts.Version = b.Version
+	return nil
+}
 
-	if err = subProcess.Start(); err != nil {
-		return fmt.Errorf("an error occured: %w", err)
+// withStepTimeout runs a step of the install, bounded by Options.StepTimeout.
+func (i *Installer) withStepTimeout(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, i.opts.StepTimeout)
+	defer cancel()
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("step timed out after %s: %w", i.opts.StepTimeout, err)
 	}
+	return err
+}
 
-	if _, err = io.Copy(stdin, in); err != nil {
-		return fmt.Errorf("an error occured: %w", err)
+func (i *Installer) hasService(s Service) bool {
+	return containsService(i.opts.Services, s)
+}
+
+func (i *Installer) hasComponent(c Component) bool {
+	return i.hasService(ServiceRedis) && containsComponent(i.opts.Components, c)
+}
+
+// serviceAddress returns the host and port at which daprd reaches a service.
+// On a network, this is the network alias and the container port; otherwise
+// it is the port published on localhost.
+func (i *Installer) serviceAddress(s Service) (string, int) {
+	network := i.opts.Network != ""
+	switch s {
+	case ServicePlacement:
+		if network {
+			return DaprPlacementContainerName, placementContainerPort
+		}
+		return daprDefaultHost, i.opts.PlacementPort
+	case ServiceRedis:
+		if network {
+			return DaprRedisContainerName, redisContainerPort
+		}
+		return daprDefaultHost, i.opts.RedisPort
+	case ServiceZipkin:
+		if network {
+			return DaprZipkinContainerName, zipkinContainerPort
+		}
+		return daprDefaultHost, i.opts.ZipkinPort
 	}
+	return "", 0
+}
 
-	stdin.Close()
+// configFile is a configuration file Install writes.
+type configFile struct {
+	path string
+	// service is the service whose address the file holds.
+	service Service
+	content func() ([]byte, error)
+}
 
-	if err = subProcess.Wait(); err != nil {
-		return fmt.Errorf("an error occured: %w", err)
+// configFiles returns the configuration files Install writes.
+func (i *Installer) configFiles() []configFile {
+	zipkinHost, zipkinPort := "", 0
+	if i.hasService(ServiceZipkin) {
+		zipkinHost, zipkinPort = i.serviceAddress(ServiceZipkin)
+	}
+	redisHost, redisPort := i.serviceAddress(ServiceRedis)
+
+	files := []configFile{{
+		path:    i.configPath,
+		service: ServiceZipkin,
+		content: func() ([]byte, error) { return defaultConfiguration(zipkinHost, zipkinPort) },
+	}}
+	if i.hasComponent(ComponentPubSub) {
+		files = append(files, configFile{
+			path:    filepath.Join(i.compDir, pubSubYamlFileName),
+			service: ServiceRedis,
+			content: func() ([]byte, error) { return redisPubSub(redisHost, redisPort) },
+		})
 	}
+	if i.hasComponent(ComponentStateStore) {
+		files = append(files, configFile{
+			path:    filepath.Join(i.compDir, stateStoreYamlFileName),
+			service: ServiceRedis,
+			content: func() ([]byte, error) { return redisStateStore(redisHost, redisPort) },
+		})
+	}
+	return files
+}
 
+func (i *Installer) writeConfiguration(tx *transaction) error {
+	for _, f := range i.configFiles() {
+		b, err := f.content()
+		if err != nil {
+			return err
+		}
+		if err = i.recordCreated(tx, f, b); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func extractTarGz(gzipStream io.Reader, base string) error {
-	uncompressedStream, err := gzip.NewReader(gzipStream)
+// configAction returns what Install does to the file at filePath, given
+// its new content b and the manifest of the previous install: the file is
+// written if it does not exist and replaced if an earlier install wrote it
+// and it is unchanged since, e.g. to point it at a new network or port.
+// Otherwise it is user-authored or edited and skipped. The current
+// content of the file is returned too.
+func (i *Installer) configAction(previous *Manifest, filePath string, b []byte) (Action, []byte, error) {
+	old, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return ActionWrite, nil, nil
+	} else if err != nil {
+		return "", nil, err
+	}
+	if bytes.Equal(old, b) {
+		return ActionSkip, old, nil
+	}
+	rel, err := filepath.Rel(i.opts.InstallDir, filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(old)
+	if f, ok := previous.file(rel); ok && f.SHA256 == hex.EncodeToString(sum[:]) {
+		return ActionReplace, old, nil
+	}
+	return ActionSkip, old, nil
+}
+
+// recordCreated writes f unless it exists and adds it to the manifest if
+// it wrote it or an earlier install did. A file an earlier install wrote
+// is replaced if it is unchanged since.
+func (i *Installer) recordCreated(tx *transaction, f configFile, b []byte) error {
+	action, old, err := i.configAction(i.previous, f.path, b)
 	if err != nil {
 		return err
 	}
+	rel, err := filepath.Rel(i.opts.InstallDir, f.path)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case ActionWrite:
+		if err = checkAndOverWriteFile(f.path, b); err != nil {
+			return err
+		}
+		tx.onRollback("remove "+f.path, func(context.Context) error {
+			return removeIfExists(f.path)
+		})
+	case ActionReplace:
+		i.message("Updating %s for the address of %s", rel, f.service)
+		tx.onRollback("restore "+f.path, func(context.Context) error {
+			// #nosec G306
+			return ioutil.WriteFile(f.path, old, 0644)
+		})
+		// #nosec G306
+		if err = ioutil.WriteFile(f.path, b, 0644); err != nil {
+			return err
+		}
+	default:
+		if prev := i.previousAddress(f.service); prev != "" && bytes.Contains(old, []byte(prev)) {
+			host, port := i.serviceAddress(f.service)
+			if cur := fmt.Sprintf("%s:%d", host, port); cur != prev && i.hasService(f.service) {
+				i.warn("%s was edited or not written by the installer and is kept, but it points at %s, which is now at %s", rel, prev, cur)
+			}
+		}
+		// Keep track of the file if an earlier install created it.
+		if pf, ok := i.previous.file(rel); ok {
+			i.manifest.addFile(pf)
+		}
+		return nil
+	}
+	return i.manifest.addFiles(i.opts.InstallDir, f.path)
+}
 
-	tarReader := tar.NewReader(uncompressedStream)
+// previousAddress returns the host and port at which the previous install
+// made s reachable, or "" if it is not known.
+func (i *Installer) previousAddress(s Service) string {
+	switch {
+	case i.previous == nil:
+		return ""
+	case i.previous.Network != "":
+		return fmt.Sprintf("%s:%d", serviceContainers[s], containerPorts[s])
+	case i.previous.Ports[s] != 0:
+		return fmt.Sprintf("%s:%d", daprDefaultHost, i.previous.Ports[s])
+	}
+	return ""
+}
 
-	removePath := filepath.Join("release", osarch)
+// installCLI extracts the embedded CLI into dir and returns the paths of
+// the extracted files.
+func (i *Installer) installCLI(ctx context.Context, dir string) ([]string, error) {
+	asset, err := i.bundle.cliAsset()
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(asset.Path)
+	f, err := assets.Open(asset.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file %s: %w", name, err)
+	}
+	defer f.Close()
 
-	for {
-		header, err := tarReader.Next()
+	var files []string
+	err = i.withStepTimeout(ctx, func(ctx context.Context) (err error) {
+		files, err = extractArchive(ctx, name, f, dir, asset.layout)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not install CLI: %w", err)
+	}
+	i.emitExtracted(name, files)
+	return files, nil
+}
 
-		if err == io.EOF {
-			break
+// installBinaries extracts the embedded binaries into dir and returns the
+// paths of the extracted files.
+func (i *Installer) installBinaries(ctx context.Context, dir string) ([]string, error) {
+	var extracted []string
+	for _, asset := range i.bundle.binaryAssets() {
+		// The embed package does not use path separators of the OS.
+		// https://github.com/golang/go/issues/44305
+		name := path.Base(asset.Path)
+		i.emit(Event{Kind: EventItem, Message: name, Name: name})
+		f, err := assets.Open(asset.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open file %s: %w", name, err)
 		}
 
+		var files []string
+		err = i.withStepTimeout(ctx, func(ctx context.Context) (err error) {
+			files, err = extractArchive(ctx, name, f, dir, asset.layout)
+			return err
+		})
+		f.Close()
 		if err != nil {
-			return fmt.Errorf("extractTarGz: Next() failed: %w", err)
+			return nil, fmt.Errorf("could not extract %s: %w", name, err)
 		}
+		i.emitExtracted(name, files)
+		extracted = append(extracted, files...)
+	}
+	return extracted, nil
+}
 
-		p := filepath.Join(base, header.Name)
-		p = strings.Replace(p, removePath, "", 1)
+// emitExtracted reports the files extracted from an archive.
+func (i *Installer) emitExtracted(archive string, files []string) {
+	var size int64
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			size += fi.Size()
+		}
+	}
+	i.emit(Event{Kind: EventExtracted, Name: archive, Bytes: size})
+}
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(p, 0755); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			outFile, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode().Perm())
-			if err != nil {
-				return err
+func (i *Installer) loadImages(ctx context.Context, tx *transaction) error {
+	images, err := i.rt.Images(ctx)
+	if err != nil {
+		return err
+	}
+	existing := newImageSet(images)
+	// An existing image may have been loaded by an earlier install.
+	manifests, err := readManifests(i.opts.InstallDir)
+	if err != nil {
+		return err
+	}
+	loadedBefore := imageSet{}
+	for _, image := range loadedImages(manifests) {
+		loadedBefore[normalizeImageRef(image)] = true
+	}
+	for _, img := range i.bundle.Images {
+		if !i.hasService(img.Role) {
+			continue
+		}
+		i.emit(Event{Kind: EventImageLoading, Message: img.Image, Service: img.Role, Name: img.Image})
+		f, err := imageArchives.Open(path.Join("images", img.File))
+		if err != nil {
+			return err
+		}
+
+		var loaded []string
+		var out outputBuffer
+		err = i.withStepTimeout(ctx, func(ctx context.Context) (err error) {
+			loaded, err = i.rt.Load(ctx, f, &out)
+			return err
+		})
+		f.Close()
+		i.flushOutput(&out)
+		if err != nil {
+			if !existing.contains(img.Image) {
+				// An interrupted load may still have tagged the image.
+				image := img.Image
+				tx.onRollback("remove image "+image, func(ctx context.Context) error {
+					if images, err := i.rt.Images(ctx); err != nil || !newImageSet(images).contains(image) {
+						return err
+					}
+					return i.rt.RemoveImage(ctx, image)
+				})
 			}
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				return err
+			return err
+		}
+		for _, image := range loaded {
+			i.emit(Event{Kind: EventImageLoaded, Service: img.Role, Name: image})
+		}
+		i.manifest.Images = append(i.manifest.Images, loaded...)
+
+		for _, image := range loaded {
+			if existing.contains(image) {
+				if !loadedBefore.contains(image) {
+					i.manifest.ExistingImages = append(i.manifest.ExistingImages, image)
+				}
+				continue
 			}
-			outFile.Close()
-
-		default:
-			return fmt.Errorf(
-				"extractTarGz: uknown type: %b in %s",
-				header.Typeflag,
-				header.Name)
+			image := image
+			tx.onRollback("remove image "+image, func(ctx context.Context) error {
+				return i.rt.RemoveImage(ctx, image)
+			})
 		}
 	}
-
 	return nil
 }
 
-func unzip(src io.ReaderAt, size int64, dest string) ([]string, error) {
-	var filenames []string
-
-	r, err := zip.NewReader(src, size)
+// requireImages checks that the image of every service is present, so that
+// the runtime never tries to pull one, e.g. on an air-gapped machine.
+func (i *Installer) requireImages(ctx context.Context) error {
+	images, err := i.rt.Images(ctx)
 	if err != nil {
-		return filenames, err
+		return err
+	}
+	present := newImageSet(images)
+	var missing []string
+	for _, s := range AllServices {
+		if !i.hasService(s) {
+			continue
+		}
+		img, err := i.bundle.image(s)
+		if err != nil {
+			return err
+		}
+		if !present.contains(img.Image) {
+			missing = append(missing, img.Image)
+			continue
+		}
+		if img.ID == "" {
+			continue
+		}
+		id, err := i.rt.ImageID(ctx, img.Image)
+		if err != nil {
+			return err
+		}
+		if !sameImageID(id, img.ID) {
+			return fmt.Errorf("image %s is %s, expected the bundled %s", img.Image, id, img.ID)
+		}
 	}
+	if len(missing) > 0 {
+		return fmt.Errorf("required images are not present after loading: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
 
-	removePath := filepath.Join("release", osarch)
+// serviceImage returns the reference to run the bundled image of s by.
+func (i *Installer) serviceImage(s Service) string {
+	// requireImages has already checked that the image exists.
+	img, _ := i.bundle.image(s)
+	return img.runRef()
+}
 
-	for _, f := range r.File {
-		// Store filename/path for returning and using later on
-		fpath := filepath.Join(dest, f.Name)
-		fpath = strings.Replace(fpath, removePath, "", 1)
+func (i *Installer) startServices(ctx context.Context, tx *transaction) error {
+	network := i.opts.Network
 
-		// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
-		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return filenames, fmt.Errorf("%s: illegal file path", fpath)
+	if network != "" {
+		if err := i.ensureNetwork(ctx, tx); err != nil {
+			return fmt.Errorf("could not create network %s: %w", network, err)
 		}
+	}
 
-		filenames = append(filenames, fpath)
-
-		if f.FileInfo().IsDir() {
-			// Make Folder
-			os.MkdirAll(fpath, os.ModePerm)
+	if i.hasService(ServicePlacement) {
+		if err := i.backupContainer(ctx, tx, DaprPlacementContainerName); err != nil {
+			return fmt.Errorf("could not stop previously installed placement service: %w", err)
+		}
+	}
+	for _, s := range []Service{ServiceRedis, ServiceZipkin} {
+		if !i.hasService(s) {
 			continue
 		}
-
-		// Make File
-		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-			return filenames, err
+		if err := i.replaceOutdatedContainer(ctx, tx, s); err != nil {
+			return fmt.Errorf("could not stop previously installed %s: %w", s, err)
 		}
+	}
 
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+	i.step("Starting %s containers...", i.rt.Name())
+	if i.hasService(ServicePlacement) {
+		i.emit(Event{Kind: EventItem, Message: "Dapr placement service", Service: ServicePlacement})
+		err := i.trackContainer(ctx, tx, ServicePlacement, DaprPlacementContainerName, func() error {
+			return runPlacementService(ctx, i.rt, i.serviceImage(ServicePlacement), network, i.opts.PlacementPort)
+		})
 		if err != nil {
-			return filenames, err
+			return fmt.Errorf("could not start placement service: %w", err)
 		}
-
-		rc, err := f.Open()
+	}
+	if i.hasService(ServiceRedis) {
+		i.emit(Event{Kind: EventItem, Message: "redis", Service: ServiceRedis})
+		err := i.trackContainer(ctx, tx, ServiceRedis, DaprRedisContainerName, func() error {
+			return runRedis(ctx, i.rt, i.serviceImage(ServiceRedis), network, i.opts.RedisPort)
+		})
+		if err != nil {
+			return fmt.Errorf("could not start redis: %w", err)
+		}
+	}
+	if i.hasService(ServiceZipkin) {
+		i.emit(Event{Kind: EventItem, Message: "openzipkin/zipkin", Service: ServiceZipkin})
+		err := i.trackContainer(ctx, tx, ServiceZipkin, DaprZipkinContainerName, func() error {
+			return runZipkin(ctx, i.rt, i.serviceImage(ServiceZipkin), network, i.opts.ZipkinPort)
+		})
 		if err != nil {
-			return filenames, err
+			return fmt.Errorf("could not start zipkin: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureNetwork creates the network if it does not exist yet.
+func (i *Installer) ensureNetwork(ctx context.Context, tx *transaction) error {
+	network := i.opts.Network
+	exists, err := i.rt.NetworkExists(ctx, network)
+	if err != nil {
+		return err
+	}
+	if exists {
+		// Keep track of the network if an earlier install created it.
+		if i.previous != nil && i.previous.Network == network {
+			i.manifest.NetworkCreated = i.previous.NetworkCreated
 		}
+		return nil
+	}
+	i.emit(Event{Kind: EventMessage, Message: "Creating network: " + network, Name: network})
+	if err = i.rt.CreateNetwork(ctx, network); err != nil {
+		return err
+	}
+	i.manifest.NetworkCreated = true
+	tx.onRollback("remove network "+network, func(ctx context.Context) error {
+		return i.rt.RemoveNetwork(ctx, network)
+	})
+	return nil
+}
 
-		_, err = io.Copy(outFile, rc)
+// backupContainer stops and renames a previously installed container so
+// that it can be restored on rollback. The backup is removed on commit.
+func (i *Installer) backupContainer(ctx context.Context, tx *transaction, serviceContainerName string) error {
+	container := createContainerName(serviceContainerName, i.opts.Network)
+	backup := container + "_previous"
 
-		// Close the file without defer to close before next iteration of loop
-		outFile.Close()
-		rc.Close()
+	// Remove a backup left behind by an interrupted install.
+	if err := i.removeDockerContainer(ctx, backup, ""); err != nil {
+		return err
+	}
 
-		if err != nil {
-			return filenames, err
+	state, err := i.rt.Inspect(ctx, container)
+	if err != nil || !state.Exists {
+		return err
+	}
+	i.emit(Event{Kind: EventMessage, Message: "Stopping container: " + container, Name: container})
+	if err = i.rt.Stop(ctx, container); err != nil {
+		return err
+	}
+	if err = i.rt.Rename(ctx, container, backup); err != nil {
+		return err
+	}
+
+	tx.onRollback("restore container "+container, func(ctx context.Context) error {
+		if err := i.removeDockerContainer(ctx, container, ""); err != nil {
+			return err
+		}
+		if err := i.rt.Rename(ctx, backup, container); err != nil {
+			return err
+		}
+		if state.Running {
+			return i.rt.Start(ctx, container)
 		}
+		return nil
+	})
+	tx.onCommit("remove container "+backup, func(ctx context.Context) error {
+		return i.rt.Remove(ctx, backup)
+	})
+	return nil
+}
+
+// replaceOutdatedContainer backs up the container of s if it is outdated,
+// so that a new container is started.
+func (i *Installer) replaceOutdatedContainer(ctx context.Context, tx *transaction, s Service) error {
+	info, err := i.rt.Inspect(ctx, createContainerName(serviceContainers[s], i.opts.Network))
+	if err != nil || !info.Exists || !i.outdated(info, s) {
+		return err
 	}
+	return i.backupContainer(ctx, tx, serviceContainers[s])
+}
 
-	return filenames, nil
+// outdated reports whether the existing container of s must be replaced:
+// when upgrading, if it runs an image other than the bundled one, and if
+// it does not publish the chosen host port.
+func (i *Installer) outdated(info ContainerInfo, s Service) bool {
+	if i.upgrading && info.Image != i.serviceImage(s) {
+		return true
+	}
+	return !i.publishesPort(info, s)
+}
+
+// trackContainer calls start and registers how to return the container to
+// its previous state: removed if it did not exist, stopped if it was not running.
+func (i *Installer) trackContainer(ctx context.Context, tx *transaction, service Service, serviceContainerName string, start func() error) error {
+	container := createContainerName(serviceContainerName, i.opts.Network)
+	state, err := i.rt.Inspect(ctx, container)
+	if err != nil {
+		return err
+	}
+
+	err = start()
+	switch {
+	case !state.Exists:
+		tx.onRollback("remove container "+container, func(ctx context.Context) error {
+			return i.removeDockerContainer(ctx, serviceContainerName, i.opts.Network)
+		})
+	case !state.Running:
+		tx.onRollback("stop container "+container, func(ctx context.Context) error {
+			return i.rt.Stop(ctx, container)
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return i.recordContainer(ctx, service, serviceContainerName)
+}
+
+func (i *Installer) recordContainer(ctx context.Context, service Service, serviceContainerName string) error {
+	name := createContainerName(serviceContainerName, i.opts.Network)
+	info, err := i.rt.Inspect(ctx, name)
+	if err != nil {
+		return err
+	}
+	i.manifest.Containers = append(i.manifest.Containers, ManifestContainer{
+		Service: service,
+		Name:    name,
+		Image:   info.Image,
+	})
+	i.emit(Event{Kind: EventContainerStarted, Service: service, Name: name})
+	return nil
 }
 
 const (
@@ -347,7 +893,7 @@ type componentMetadataItem struct {
 	Value string `yaml:"value"`
 }
 
-func createRedisStateStore(redisHost string, componentsPath string) error {
+func redisStateStore(redisHost string, redisPort int) ([]byte, error) {
 	redisStore := component{
 		APIVersion: "dapr.io/v1alpha1",
 		Kind:       "Component",
@@ -359,7 +905,7 @@ func createRedisStateStore(redisHost string, componentsPath string) error {
 	redisStore.Spec.Metadata = []componentMetadataItem{
 		{
 			Name:  "redisHost",
-			Value: fmt.Sprintf("%s:6379", redisHost),
+			Value: fmt.Sprintf("%s:%d", redisHost, redisPort),
 		},
 		{
 			Name:  "redisPassword",
@@ -371,18 +917,10 @@ func createRedisStateStore(redisHost string, componentsPath string) error {
 		},
 	}
 
-	b, err := yaml.Marshal(&redisStore)
-	if err != nil {
-		return err
-	}
-
-	filePath := filepath.Join(componentsPath, stateStoreYamlFileName)
-	err = checkAndOverWriteFile(filePath, b)
-
-	return err
+	return yaml.Marshal(&redisStore)
 }
 
-func createRedisPubSub(redisHost string, componentsPath string) error {
+func redisPubSub(redisHost string, redisPort int) ([]byte, error) {
 	redisPubSub := component{
 		APIVersion: "dapr.io/v1alpha1",
 		Kind:       "Component",
@@ -394,7 +932,7 @@ func createRedisPubSub(redisHost string, componentsPath string) error {
 	redisPubSub.Spec.Metadata = []componentMetadataItem{
 		{
 			Name:  "redisHost",
-			Value: fmt.Sprintf("%s:6379", redisHost),
+			Value: fmt.Sprintf("%s:%d", redisHost, redisPort),
 		},
 		{
 			Name:  "redisPassword",
@@ -402,18 +940,10 @@ func createRedisPubSub(redisHost string, componentsPath string) error {
 		},
 	}
 
-	b, err := yaml.Marshal(&redisPubSub)
-	if err != nil {
-		return err
-	}
-
-	filePath := filepath.Join(componentsPath, pubSubYamlFileName)
-	err = checkAndOverWriteFile(filePath, b)
-
-	return err
+	return yaml.Marshal(&redisPubSub)
 }
 
-func createDefaultConfiguration(zipkinHost, filePath string) error {
+func defaultConfiguration(zipkinHost string, zipkinPort int) ([]byte, error) {
 	defaultConfig := configuration{
 		APIVersion: "dapr.io/v1alpha1",
 		Kind:       "Configuration",
@@ -421,16 +951,9 @@ func createDefaultConfiguration(zipkinHost, filePath string) error {
 	defaultConfig.Metadata.Name = "daprConfig"
 	if zipkinHost != "" {
 		defaultConfig.Spec.Tracing.SamplingRate = "1"
-		defaultConfig.Spec.Tracing.Zipkin.EndpointAddress = fmt.Sprintf("http://%s:9411/api/v2/spans", zipkinHost)
-	}
-	b, err := yaml.Marshal(&defaultConfig)
-	if err != nil {
-		return err
+		defaultConfig.Spec.Tracing.Zipkin.EndpointAddress = fmt.Sprintf("http://%s:%d/api/v2/spans", zipkinHost, zipkinPort)
 	}
-
-	err = checkAndOverWriteFile(filePath, b)
-
-	return err
+	return yaml.Marshal(&defaultConfig)
 }
 
 func checkAndOverWriteFile(filePath string, b []byte) error {
@@ -444,196 +967,120 @@ func checkAndOverWriteFile(filePath string, b []byte) error {
 	return nil
 }
 
-func removeDockerContainer(containerName, network string) error {
+func (i *Installer) removeDockerContainer(ctx context.Context, containerName, network string) error {
 	container := createContainerName(containerName, network)
-	exists, _ := confirmContainerIsRunningOrExists(container, false)
-	if !exists {
+	info, _ := i.rt.Inspect(ctx, container)
+	if !info.Exists {
 		return nil
 	}
-	fmt.Printf("Removing container: %s\n", container)
-	_, err := RunCmdAndWait(
-		"docker", "rm",
-		"--force",
-		container)
-
-	return err
+	i.emit(Event{Kind: EventMessage, Message: "Removing container: " + container, Name: container})
+	return i.rt.Remove(ctx, container)
 }
 
-func runPlacementService(version string, dockerNetwork string) error {
+func runPlacementService(ctx context.Context, rt ContainerRuntime, image string, dockerNetwork string, port int) error {
 	placementContainerName := createContainerName(DaprPlacementContainerName, dockerNetwork)
 
-	image := fmt.Sprintf("%s:%s", daprDockerImageName, version)
-
-	exists, err := confirmContainerIsRunningOrExists(placementContainerName, false)
+	info, err := rt.Inspect(ctx, placementContainerName)
 	if err != nil {
 		return err
-	} else if exists {
+	} else if info.Exists {
 		return fmt.Errorf("%s container exists or is running", placementContainerName)
 	}
 
-	args := []string{
-		"run",
-		"--name", placementContainerName,
-		"--restart", "always",
-		"-d",
-		"--entrypoint", "./placement",
+	spec := ContainerSpec{
+		Name:       placementContainerName,
+		Image:      image,
+		Entrypoint: "./placement",
+		Restart:    "always",
 	}
 
 	if dockerNetwork != "" {
-		args = append(args,
-			"--network", dockerNetwork,
-			"--network-alias", DaprPlacementContainerName)
+		spec.Network = dockerNetwork
+		spec.NetworkAlias = DaprPlacementContainerName
 	} else {
-		osPort := 50005
-		if runtime.GOOS == "windows" {
-			osPort = 6050
-		}
-
-		args = append(args,
-			"-p", fmt.Sprintf("%v:50005", osPort))
+		spec.Ports = []PortBinding{{HostPort: port, ContainerPort: placementContainerPort}}
 	}
 
-	args = append(args, image)
-
-	_, err = RunCmdAndWait("docker", args...)
-
-	if err != nil {
-		runError := isContainerRunError(err)
-		if !runError {
-			return parseDockerError("placement service", err)
-		} else {
-			return fmt.Errorf("docker %s failed with: %v", args, err)
-		}
-	}
-
-	return nil
+	return runContainer(ctx, rt, "placement service", spec)
 }
 
-func runZipkin(dockerNetwork string) error {
+func runZipkin(ctx context.Context, rt ContainerRuntime, image string, dockerNetwork string, port int) error {
 	zipkinContainerName := createContainerName(DaprZipkinContainerName, dockerNetwork)
 
-	exists, err := confirmContainerIsRunningOrExists(zipkinContainerName, false)
+	info, err := rt.Inspect(ctx, zipkinContainerName)
 	if err != nil {
 		return err
 	}
-	args := []string{}
-
-	if exists {
+	if info.Exists {
 		// do not create container again if it exists
-		args = append(args, "start", zipkinContainerName)
-	} else {
-		args = append(args,
-			"run",
-			"--name", zipkinContainerName,
-			"--restart", "always",
-			"-d",
-		)
-
-		if dockerNetwork != "" {
-			args = append(
-				args,
-				"--network", dockerNetwork,
-				"--network-alias", DaprZipkinContainerName)
-		} else {
-			args = append(
-				args,
-				"-p", "9411:9411")
-		}
+		return rt.Start(ctx, zipkinContainerName)
+	}
 
-		args = append(args, "openzipkin/zipkin")
+	spec := ContainerSpec{
+		Name:    zipkinContainerName,
+		Image:   image,
+		Restart: "always",
 	}
-	_, err = RunCmdAndWait("docker", args...)
 
-	if err != nil {
-		runError := isContainerRunError(err)
-		if !runError {
-			return parseDockerError("Zipkin tracing", err)
-		} else {
-			return fmt.Errorf("docker %s failed with: %v", args, err)
-		}
+	if dockerNetwork != "" {
+		spec.Network = dockerNetwork
+		spec.NetworkAlias = DaprZipkinContainerName
+	} else {
+		spec.Ports = []PortBinding{{HostPort: port, ContainerPort: zipkinContainerPort}}
 	}
 
-	return nil
+	return runContainer(ctx, rt, "Zipkin tracing", spec)
 }
 
-func runRedis(dockerNetwork string) error {
+func runRedis(ctx context.Context, rt ContainerRuntime, image string, dockerNetwork string, port int) error {
 	redisContainerName := createContainerName(DaprRedisContainerName, dockerNetwork)
 
-	exists, err := confirmContainerIsRunningOrExists(redisContainerName, false)
+	info, err := rt.Inspect(ctx, redisContainerName)
 	if err != nil {
 		return err
 	}
-	args := []string{}
-
-	if exists {
+	if info.Exists {
 		// do not create container again if it exists
-		args = append(args, "start", redisContainerName)
-	} else {
-		args = append(args,
-			"run",
-			"--name", redisContainerName,
-			"--restart", "always",
-			"-d",
-		)
-
-		if dockerNetwork != "" {
-			args = append(
-				args,
-				"--network", dockerNetwork,
-				"--network-alias", DaprRedisContainerName)
-		} else {
-			args = append(
-				args,
-				"-p", "6379:6379")
-		}
+		return rt.Start(ctx, redisContainerName)
+	}
 
-		args = append(args, "redis")
+	spec := ContainerSpec{
+		Name:    redisContainerName,
+		Image:   image,
+		Restart: "always",
 	}
-	_, err = RunCmdAndWait("docker", args...)
 
-	if err != nil {
-		runError := isContainerRunError(err)
-		if !runError {
-			return parseDockerError("Redis state store", err)
-		} else {
-			return fmt.Errorf("docker %s failed with: %v", args, err)
-		}
+	if dockerNetwork != "" {
+		spec.Network = dockerNetwork
+		spec.NetworkAlias = DaprRedisContainerName
+	} else {
+		spec.Ports = []PortBinding{{HostPort: port, ContainerPort: redisContainerPort}}
 	}
 
-	return nil
+	return runContainer(ctx, rt, "Redis state store", spec)
 }
 
-// check if the container either exists and stopped or is running.
-func confirmContainerIsRunningOrExists(containerName string, isRunning bool) (bool, error) {
-	// e.g. docker ps --filter name=dapr_redis --filter status=running --format {{.Names}}
-
-	args := []string{"ps", "--all", "--filter", "name=" + containerName}
-
-	if isRunning {
-		args = append(args, "--filter", "status=running")
-	}
-
-	args = append(args, "--format", "{{.Names}}")
-	response, err := RunCmdAndWait("docker", args...)
-	response = strings.TrimSuffix(response, "\n")
+func runContainer(ctx context.Context, rt ContainerRuntime, component string, spec ContainerSpec) error {
+	err := rt.Run(ctx, spec)
 
-	// If 'docker ps' failed due to some reason
 	if err != nil {
-		return false, fmt.Errorf("unable to confirm whether %s is running or exists. error\n%v", containerName, err.Error())
-	}
-	// 'docker ps' worked fine, but the response did not have the container name
-	if response == "" || response != containerName {
-		if isRunning {
-			return false, fmt.Errorf("container %s is not running", containerName)
+		runError := isContainerRunError(err)
+		if !runError {
+			return parseDockerError(component, err)
+		} else {
+			return fmt.Errorf("%s run %s failed with: %w", rt.Name(), spec.Name, err)
 		}
-		return false, nil
 	}
 
-	return true, nil
+	return nil
 }
 
 func parseDockerError(component string, err error) error {
-	if exitError, ok := err.(*exec.ExitError); ok {
+	if errors.Is(err, ErrNameConflict) || errors.Is(err, ErrPortAllocated) || errors.Is(err, ErrDaemonNotRunning) {
+		return fmt.Errorf("failed to launch %s: %w", component, err)
+	}
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
 		exitCode := exitError.ExitCode()
 		if exitCode == 125 { // see https://github.com/moby/moby/pull/14012
 			return fmt.Errorf("failed to launch %s. Is it already running?", component)
@@ -646,7 +1093,8 @@ func parseDockerError(component string, err error) error {
 }
 
 func isContainerRunError(err error) bool {
-	if exitError, ok := err.(*exec.ExitError); ok {
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
 		exitCode := exitError.ExitCode()
 		return exitCode == 125
 	}
@@ -662,7 +1110,13 @@ func createContainerName(serviceContainerName string, dockerNetwork string) stri
 }
 
 func RunCmdAndWait(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
+	return RunCmdAndWaitContext(context.Background(), name, args...)
+}
+
+// RunCmdAndWaitContext is like RunCmdAndWait but kills the command when
+// ctx is done.
+func RunCmdAndWaitContext(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -684,14 +1138,17 @@ func RunCmdAndWait(name string, args ...string) (string, error) {
 	}
 	errB, err := ioutil.ReadAll(stderr)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	err = cmd.Wait()
 	if err != nil {
-		// in case of error, capture the exact message
-		if len(errB) > 0 {
-			return "", errors.New(string(errB))
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		// in case of error, capture the exact message and keep the exit code
+		if msg := strings.TrimSpace(string(errB)); msg != "" {
+			return "", fmt.Errorf("%s: %w", msg, err)
 		}
 		return "", err
 	}
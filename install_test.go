@@ -0,0 +1,280 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestInstallRollsBack(t *testing.T) {
+	useTestBundle(t, "v1.6.0", "v1.5.1")
+	tests := []struct {
+		name string
+		// previous is the version installed before, "" for none.
+		previous string
+		fail     string
+	}{
+		{name: "network", fail: "CreateNetwork n"},
+		{name: "image", fail: "Load " + testImage(ServiceRedis, "v1.6.0")},
+		{name: "first container", fail: "Run " + createContainerName(DaprPlacementContainerName, "n")},
+		{name: "last container", fail: "Run " + createContainerName(DaprZipkinContainerName, "n")},
+		{name: "image over an install", previous: "v1.5.1", fail: "Load " + testImage(ServiceZipkin, "v1.6.0")},
+		{name: "placement over an install", previous: "v1.5.1", fail: "Run " + createContainerName(DaprPlacementContainerName, "n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rt := newFakeRuntime()
+			if tt.previous != "" {
+				rt.queueLoads(tt.previous, AllServices...)
+				if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", Version: tt.previous}).Install(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := snapshotRuntime(rt)
+			beforeFiles := snapshotFiles(t, dir)
+
+			rt.queueLoads("v1.6.0", AllServices...)
+			rt.fail[tt.fail] = errors.New("failed")
+			err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n"}).Install(context.Background())
+			if err == nil {
+				t.Fatal("Install() succeeded, want an error")
+			}
+
+			if after := snapshotRuntime(rt); after != before {
+				t.Errorf("runtime after rollback:\n%s\nwant\n%s", after, before)
+			}
+			afterFiles := snapshotFiles(t, dir)
+			for p, content := range beforeFiles {
+				if afterFiles[p] != content {
+					t.Errorf("%s = %q after rollback, want %q", p, afterFiles[p], content)
+				}
+			}
+			for p := range afterFiles {
+				if _, ok := beforeFiles[p]; !ok {
+					t.Errorf("%s was left behind", p)
+				}
+			}
+			if tt.previous != "" {
+				if v, err := newTestInstaller(t, rt, Options{InstallDir: dir}).currentVersion(); err != nil || v != tt.previous {
+					t.Errorf("currentVersion() = %q, %v, want %q", v, err, tt.previous)
+				}
+			}
+		})
+	}
+}
+
+// snapshotRuntime describes the containers, images and networks of rt.
+func snapshotRuntime(rt *fakeRuntime) string {
+	var s []string
+	for name, c := range rt.containers {
+		state := "stopped"
+		if c.Running {
+			state = "running"
+		}
+		s = append(s, "container "+name+" "+c.Image+" "+state)
+	}
+	for image := range rt.images {
+		s = append(s, "image "+image)
+	}
+	for network := range rt.networks {
+		s = append(s, "network "+network)
+	}
+	sort.Strings(s)
+	return strings.Join(s, "\n")
+}
+
+// snapshotFiles maps the files and links under dir, relative to it, to
+// their contents or targets.
+func snapshotFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		var content string
+		if isLink(d.Type()) {
+			content, err = os.Readlink(p)
+			content = "-> " + content
+		} else {
+			var b []byte
+			b, err = os.ReadFile(p)
+			content = string(b)
+		}
+		files[filepath.ToSlash(rel)] = content
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestInstallOnNetworkRewritesConfiguration(t *testing.T) {
+	useTestBundle(t, "v1.6.0")
+	stubProbes(t)
+	dir := t.TempDir()
+	rt := newFakeRuntime()
+	ports := Options{PlacementPort: freeTestPort(t), RedisPort: freeTestPort(t), ZipkinPort: freeTestPort(t)}
+	// install returns the warnings about configuration files.
+	install := func(network string) ([]string, error) {
+		opts := ports
+		opts.InstallDir, opts.Network = dir, network
+		rt.queueLoads("v1.6.0", AllServices...)
+		var warnings []string
+		opts.Observer = ObserverFunc(func(e Event) {
+			if e.Kind == EventWarning && strings.Contains(e.Message, ".yaml") {
+				warnings = append(warnings, e.Message)
+			}
+		})
+		err := newTestInstaller(t, rt, opts).Install(context.Background())
+		return warnings, err
+	}
+
+	opts := ports
+	opts.InstallDir = dir
+	rt.queueLoads("v1.6.0", AllServices...)
+	if err := newTestInstaller(t, rt, opts).Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The user edits pubsub.yaml, which is then theirs.
+	pubsub := filepath.Join(dir, "components", pubSubYamlFileName)
+	b, err := os.ReadFile(pubsub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := "# mine\n" + string(b)
+	writeTestFile(t, pubsub, edited)
+
+	opts.Network = "n"
+	plan, err := newTestInstaller(t, rt, opts).Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Action{
+		"config.yaml":                ActionReplace,
+		"components/pubsub.yaml":     ActionSkip,
+		"components/statestore.yaml": ActionReplace,
+		ManifestFileName:             ActionWrite,
+	}
+	for _, f := range plan.Files {
+		rel, _ := filepath.Rel(dir, f.Path)
+		if f.Action != want[filepath.ToSlash(rel)] {
+			t.Errorf("planned %s %s, want %s", f.Action, rel, want[filepath.ToSlash(rel)])
+		}
+	}
+
+	warnings, err := install("n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The edited file still points at the old port.
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "pubsub.yaml") {
+		t.Errorf("warnings = %q, want one about pubsub.yaml", warnings)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for f, address := range map[string]string{
+		"config.yaml":                "http://dapr_zipkin:9411/api/v2/spans",
+		"components/statestore.yaml": "dapr_redis:6379",
+		"components/pubsub.yaml":     fmt.Sprintf("localhost:%d", ports.RedisPort),
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), address) {
+			t.Errorf("%s does not point at %s:\n%s", f, address, b)
+		}
+		if _, ok := m.file(f); !ok {
+			t.Errorf("%s is not in the manifest", f)
+		}
+	}
+	if b, _ := os.ReadFile(pubsub); string(b) != edited {
+		t.Errorf("the edited pubsub.yaml was changed:\n%s", b)
+	}
+
+	// A failed install restores the rewritten files.
+	before := snapshotFiles(t, dir)
+	rt.fail["Run "+DaprPlacementContainerName] = errors.New("failed")
+	if _, err = install(""); err == nil {
+		t.Fatal("Install() succeeded, want an error")
+	}
+	after := snapshotFiles(t, dir)
+	for _, f := range []string{"config.yaml", "components/statestore.yaml"} {
+		if after[f] != before[f] {
+			t.Errorf("%s = %q after rollback, want %q", f, after[f], before[f])
+		}
+	}
+}
+
+func TestRunCmdAndWaitContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are shell scripts")
+	}
+	tests := []struct {
+		name     string
+		script   string
+		want     string
+		wantCode int
+		// wantMsg is contained in the error and wantParsed in the error
+		// parseDockerError returns for it.
+		wantMsg, wantParsed string
+	}{
+		{name: "success", script: "echo out; echo warning >&2", want: "out\n"},
+		{name: "stderr", script: "echo out; echo 'no such image' >&2; exit 1", wantCode: 1, wantMsg: "no such image"},
+		{name: "no stderr", script: "exit 3", wantCode: 3, wantMsg: "exit status 3"},
+		{name: "run error", script: "echo 'Conflict' >&2; exit 125", wantCode: 125, wantMsg: "Conflict", wantParsed: "Is it already running?"},
+		{name: "not found", script: "exit 127", wantCode: 127, wantParsed: "Make sure Docker is installed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RunCmdAndWaitContext(context.Background(), "sh", "-c", tt.script)
+			if tt.wantCode == 0 {
+				if err != nil || got != tt.want {
+					t.Errorf("RunCmdAndWaitContext() = %q, %v, want %q", got, err, tt.want)
+				}
+				return
+			}
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != tt.wantCode {
+				t.Fatalf("RunCmdAndWaitContext() error = %v, want exit code %d", err, tt.wantCode)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("error %q does not contain %q", err, tt.wantMsg)
+			}
+			if got := isContainerRunError(err); got != (tt.wantCode == 125) {
+				t.Errorf("isContainerRunError() = %v", got)
+			}
+			if parsed := parseDockerError("redis", err); !strings.Contains(parsed.Error(), tt.wantParsed) {
+				t.Errorf("parseDockerError() = %q, want it to contain %q", parsed, tt.wantParsed)
+			}
+		})
+	}
+}
+
+func TestRunCmdAndWaitContextCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is a shell script")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunCmdAndWaitContext(ctx, "sh", "-c", "sleep 10"); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunCmdAndWaitContext() error = %v, want %v", err, context.Canceled)
+	}
+}
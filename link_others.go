@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package standalone
+
+import "os"
+
+// createLink links the directory name to target, which is relative to the
+// directory of name.
+func createLink(target, name string) error {
+	return os.Symlink(target, name)
+}
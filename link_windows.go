@@ -0,0 +1,28 @@
+package standalone
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// createLink links the directory name to target, which is relative to the
+// directory of name. Symbolic links require Developer Mode or an elevated
+// prompt, so a directory junction is created when they are not allowed.
+func createLink(target, name string) error {
+	if err := os.Symlink(target, name); err == nil {
+		return nil
+	}
+	// Junctions need an absolute target.
+	abs, err := filepath.Abs(filepath.Join(filepath.Dir(name), target))
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("cmd", "/c", "mklink", "/J", name, abs).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("could not create junction %s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package standalone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestFileName is the name of the install manifest in the install directory.
+const ManifestFileName = ".standalone-install.json"
+
+// Manifest records everything an install created so that it can be
+// inspected, verified or reversed later.
+type Manifest struct {
+	// Version is the installed Dapr version.
+	Version string `json:"version"`
+	// InstalledAt is when the install started.
+	InstalledAt time.Time `json:"installedAt"`
+	// Network is the Docker network the containers were attached to.
+	Network string `json:"network,omitempty"`
+	// NetworkCreated is true if the installer created the network.
+	NetworkCreated bool `json:"networkCreated,omitempty"`
+	// Ports maps the services to their host ports, unless the containers
+	// are attached to Network.
+	Ports map[Service]int `json:"ports,omitempty"`
+	// Complete is false if the install failed part way through.
+	Complete bool `json:"complete"`
+	// Error is the error the install failed with, if any.
+	Error string `json:"error,omitempty"`
+	// Files lists the files written, relative to the install directory.
+	Files []ManifestFile `json:"files"`
+	// Images lists the images loaded into Docker.
+	Images []string `json:"images"`
+	// ExistingImages lists the Images that existed before an install
+	// loaded them. Uninstall keeps them.
+	ExistingImages []string `json:"existingImages,omitempty"`
+	// Containers lists the containers started.
+	Containers []ManifestContainer `json:"containers"`
+}
+
+// ManifestFile is a file written by the installer.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestContainer is a container started by the installer.
+type ManifestContainer struct {
+	Service Service `json:"service"`
+	Name    string  `json:"name"`
+	Image   string  `json:"image"`
+}
+
+// ReadManifest reads the install manifest from installDir.
+func ReadManifest(installDir string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(installDir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// readManifests reads the manifest of the install directory and those of
+// every installed version, skipping the missing ones.
+func readManifests(installDir string) ([]*Manifest, error) {
+	dirs := []string{installDir}
+	entries, err := os.ReadDir(filepath.Join(installDir, versionsDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(installDir, versionsDirName, e.Name()))
+		}
+	}
+
+	var manifests []*Manifest
+	for _, dir := range dirs {
+		m, err := ReadManifest(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+// loadedImages returns the images the installs recorded in manifests
+// loaded, except those that existed before.
+func loadedImages(manifests []*Manifest) []string {
+	existing := map[string]bool{}
+	for _, m := range manifests {
+		for _, image := range m.ExistingImages {
+			existing[normalizeImageRef(image)] = true
+		}
+	}
+	seen := map[string]bool{}
+	var loaded []string
+	for _, m := range manifests {
+		for _, image := range m.Images {
+			ref := normalizeImageRef(image)
+			if !existing[ref] && !seen[ref] {
+				seen[ref] = true
+				loaded = append(loaded, image)
+			}
+		}
+	}
+	return loaded
+}
+
+func (m *Manifest) write(installDir string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	// #nosec G306
+	return ioutil.WriteFile(filepath.Join(installDir, ManifestFileName), b, 0644)
+}
+
+// addFiles records the regular files among paths, skipping directories.
+func (m *Manifest) addFiles(installDir string, paths ...string) error {
+	for _, p := range paths {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		sum, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(installDir, p)
+		if err != nil {
+			return err
+		}
+		m.addFile(ManifestFile{Path: filepath.ToSlash(rel), SHA256: sum})
+	}
+	return nil
+}
+
+// file returns the entry for the path relative to the install directory.
+func (m *Manifest) file(rel string) (ManifestFile, bool) {
+	if m != nil {
+		for _, f := range m.Files {
+			if f.Path == filepath.ToSlash(rel) {
+				return f, true
+			}
+		}
+	}
+	return ManifestFile{}, false
+}
+
+// addFile records f, replacing an earlier entry for the same path.
+func (m *Manifest) addFile(f ManifestFile) {
+	for idx := range m.Files {
+		if m.Files[idx].Path == f.Path {
+			m.Files[idx] = f
+			return
+		}
+	}
+	m.Files = append(m.Files, f)
+}
+
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
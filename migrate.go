@@ -0,0 +1,277 @@
+package standalone
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	// Unlike yaml.v2, used for the files the installer writes, yaml.v3
+	// edits documents as nodes, which keeps the comments, key order and
+	// formatting of the user's files.
+	"gopkg.in/yaml.v3"
+)
+
+// migration is a change to config.yaml or the component files that a
+// release requires, e.g. a new apiVersion or a renamed metadata key.
+// Upgrading applies the migrations of every release after the installed
+// one, up to and including the one being installed, in order.
+type migration struct {
+	// Version is the release that requires the change.
+	Version string
+	// Description says what is changed.
+	Description string
+	// Kind is the kind of the documents changed: Component or Configuration.
+	Kind string
+	// Type limits the migration to components of a type, e.g. "state.redis".
+	Type string
+	// Apply changes the mapping node of a document in place and reports
+	// whether anything changed.
+	Apply func(doc *yaml.Node) bool
+}
+
+// migrations lists the changes by release. The bundled releases, v1.5.0
+// to v1.6.0, did not change the schema of the files the installer writes;
+// a release that does adds its steps here, e.g.
+//
+//	{Version: "v1.7.0", Description: "rename oldKey to newKey",
+//		Kind: "Component", Type: "state.redis",
+//		Apply: renameMetadata("oldKey", "newKey")}
+var migrations = []migration{
+	{
+		Version:     "v1.0.0",
+		Description: "set spec.version, which is required since Dapr 1.0",
+		Kind:        "Component",
+		Apply:       setDefault([]string{"spec", "version"}, "v1"),
+	},
+}
+
+// migrateFiles applies the migrations of the releases after from, up to
+// and including to, to config.yaml and the files in the components
+// directory. Only the files that changed are written and their paths are
+// returned, also if it fails.
+func (i *Installer) migrateFiles(from, to string) ([]string, error) {
+	var steps []migration
+	for _, m := range migrations {
+		if compareVersions(m.Version, from) > 0 && compareVersions(m.Version, to) <= 0 {
+			steps = append(steps, m)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	paths := []string{i.configPath}
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(i.compDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	i.step("Migrating configuration from %s to %s...", from, to)
+	var migrated []string
+	for _, p := range paths {
+		// A file that could not be written is returned too, so that it
+		// is restored from the backup.
+		changed, err := i.migrateFile(p, steps)
+		if changed {
+			migrated = append(migrated, p)
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("could not migrate %s: %w", p, err)
+		}
+	}
+	return migrated, nil
+}
+
+// migrateFile applies steps to every document in the file at p. The
+// documents are edited as YAML nodes so that their comments are kept.
+func (i *Installer) migrateFile(p string, steps []migration) (bool, error) {
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return false, err
+	}
+
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var doc yaml.Node
+		if err = dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			// Not a file the installer can migrate, e.g. a template.
+			i.warn("Skipping %s: %v", p, err)
+			return false, nil
+		}
+		docs = append(docs, &doc)
+	}
+
+	changed := false
+	for _, doc := range docs {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		m := doc.Content[0]
+		kind := valueOf(m, "kind")
+		for _, s := range steps {
+			if s.Kind != kind || (s.Type != "" && s.Type != valueOf(mapValue(m, "spec"), "type")) {
+				continue
+			}
+			if s.Apply(m) {
+				i.item("%s: %s", filepath.Base(p), s.Description)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err = enc.Encode(doc); err != nil {
+			return false, err
+		}
+	}
+	if err = enc.Close(); err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(p, buf.Bytes(), fi.Mode().Perm())
+}
+
+// setDefault returns a migration step that sets the value at path if it
+// is not set yet.
+func setDefault(path []string, value string) func(*yaml.Node) bool {
+	return func(doc *yaml.Node) bool {
+		m := doc
+		for _, key := range path[:len(path)-1] {
+			if m = mapValue(m, key); m == nil || m.Kind != yaml.MappingNode {
+				return false
+			}
+		}
+		key := path[len(path)-1]
+		if mapValue(m, key) != nil {
+			return false
+		}
+		m.Content = append(m.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+		return true
+	}
+}
+
+// replaceAPIVersion returns a migration step that replaces the apiVersion
+// from with to.
+func replaceAPIVersion(from, to string) func(*yaml.Node) bool {
+	return func(doc *yaml.Node) bool {
+		v := mapValue(doc, "apiVersion")
+		if v == nil || v.Kind != yaml.ScalarNode || v.Value != from {
+			return false
+		}
+		v.Value = to
+		return true
+	}
+}
+
+// renameMetadata returns a migration step that renames the component
+// metadata item from to to, unless an item named to already exists.
+func renameMetadata(from, to string) func(*yaml.Node) bool {
+	return func(doc *yaml.Node) bool {
+		items := mapValue(mapValue(doc, "spec"), "metadata")
+		if items == nil || items.Kind != yaml.SequenceNode {
+			return false
+		}
+		var name *yaml.Node
+		for _, item := range items.Content {
+			n := mapValue(item, "name")
+			if n == nil || n.Kind != yaml.ScalarNode {
+				continue
+			}
+			switch n.Value {
+			case to:
+				return false
+			case from:
+				name = n
+			}
+		}
+		if name == nil {
+			return false
+		}
+		name.Value = to
+		return true
+	}
+}
+
+// mapValue returns the value of key in the mapping node m, or nil.
+func mapValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(m.Content); idx += 2 {
+		if m.Content[idx].Value == key {
+			return m.Content[idx+1]
+		}
+	}
+	return nil
+}
+
+// valueOf returns the scalar value of key in the mapping node m, or "".
+func valueOf(m *yaml.Node, key string) string {
+	v := mapValue(m, key)
+	if v == nil || v.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return v.Value
+}
+
+// compareVersions compares release versions such as "v1.6.0" by their
+// major, minor and patch numbers and returns -1, 0 or 1. A pre-release,
+// e.g. "v1.6.0-rc.1", comes before the release.
+func compareVersions(a, b string) int {
+	aNum, aPre := splitVersion(a)
+	bNum, bPre := splitVersion(b)
+	for idx := range aNum {
+		switch {
+		case aNum[idx] < bNum[idx]:
+			return -1
+		case aNum[idx] > bNum[idx]:
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return strings.Compare(aPre, bPre)
+}
+
+func splitVersion(v string) ([3]int, string) {
+	v = strings.TrimPrefix(v, "v")
+	pre := ""
+	if idx := strings.IndexByte(v, '-'); idx >= 0 {
+		v, pre = v[:idx], v[idx+1:]
+	}
+	var nums [3]int
+	for idx, part := range strings.SplitN(v, ".", 3) {
+		nums[idx], _ = strconv.Atoi(part)
+	}
+	return nums, pre
+}
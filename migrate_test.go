@@ -0,0 +1,291 @@
+package standalone
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateFiles(t *testing.T) {
+	const component = `# The state store of the app.
+apiVersion: dapr.io/v1alpha1
+kind: Component
+metadata:
+  name: statestore
+spec:
+  type: state.redis # a local Redis
+  metadata:
+    - name: redisHost
+      value: localhost:6379
+`
+	const migrated = `# The state store of the app.
+apiVersion: dapr.io/v1alpha1
+kind: Component
+metadata:
+  name: statestore
+spec:
+  type: state.redis # a local Redis
+  metadata:
+    - name: redisHost
+      value: localhost:6379
+  version: v1
+`
+	tests := []struct {
+		name     string
+		from, to string
+		// files maps the paths relative to the install directory to
+		// their contents.
+		files map[string]string
+		// want maps the paths that are migrated to their new contents.
+		want map[string]string
+	}{
+		{
+			name:  "sets spec.version and keeps comments",
+			from:  "v0.11.3",
+			to:    "v1.6.0",
+			files: map[string]string{"components/statestore.yaml": component},
+			want:  map[string]string{"components/statestore.yaml": migrated},
+		},
+		{
+			name:  "no steps after the installed version",
+			from:  "v1.0.0",
+			to:    "v1.6.0",
+			files: map[string]string{"components/statestore.yaml": component},
+		},
+		{
+			name:  "no steps up to the new version",
+			from:  "v0.10.0",
+			to:    "v1.0.0-rc.1",
+			files: map[string]string{"components/statestore.yaml": component},
+		},
+		{
+			name:  "already migrated",
+			from:  "v0.11.3",
+			to:    "v1.6.0",
+			files: map[string]string{"components/statestore.yml": migrated},
+		},
+		{
+			name: "every document of a file",
+			from: "v0.11.3",
+			to:   "v1.6.0",
+			files: map[string]string{
+				"components/all.yaml": component + "---\n" + migrated + "---\napiVersion: dapr.io/v1alpha1\nkind: Component\nmetadata:\n  name: pubsub\nspec:\n  type: pubsub.redis\n",
+			},
+			want: map[string]string{
+				"components/all.yaml": migrated + "---\n" + migrated + "---\napiVersion: dapr.io/v1alpha1\nkind: Component\nmetadata:\n  name: pubsub\nspec:\n  type: pubsub.redis\n  version: v1\n",
+			},
+		},
+		{
+			name: "only components",
+			from: "v0.11.3",
+			to:   "v1.6.0",
+			files: map[string]string{
+				"config.yaml": "apiVersion: dapr.io/v1alpha1\nkind: Configuration\nmetadata:\n  name: daprConfig\nspec:\n  tracing:\n    samplingRate: \"1\"\n",
+			},
+		},
+		{
+			name: "skips files that are not YAML",
+			from: "v0.11.3",
+			to:   "v1.6.0",
+			files: map[string]string{
+				"components/template.yaml": "kind: Component\nspec: {{ .Spec }\n",
+				"components/notes.txt":     "kind: Component\nspec:\n  type: state.redis\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for p, content := range tt.files {
+				writeTestFile(t, filepath.Join(dir, p), content)
+			}
+			i := newTestInstaller(t, newFakeRuntime(), Options{InstallDir: dir})
+			got, err := i.migrateFiles(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			for p := range tt.want {
+				want = append(want, filepath.Join(dir, p))
+			}
+			if !sameStrings(got, want) {
+				t.Errorf("migrateFiles() = %v, want %v", got, want)
+			}
+			for p, content := range tt.files {
+				if w, ok := tt.want[p]; ok {
+					content = w
+				}
+				b, err := os.ReadFile(filepath.Join(dir, p))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != content {
+					t.Errorf("%s =\n%s\nwant\n%s", p, b, content)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrationSteps(t *testing.T) {
+	const component = `apiVersion: dapr.io/v1alpha1
+kind: Component
+metadata:
+  name: statestore
+spec:
+  type: state.redis
+  metadata:
+    # The address of Redis.
+    - name: redisHost
+      value: localhost:6379
+    - name: maxRetries
+      value: "3"
+`
+	tests := []struct {
+		name  string
+		apply func(*yaml.Node) bool
+		doc   string
+		// want is the migrated document, "" if it is unchanged.
+		want string
+	}{
+		{
+			name:  "replace apiVersion",
+			apply: replaceAPIVersion("dapr.io/v1alpha1", "dapr.io/v2alpha1"),
+			doc:   component,
+			want:  strings.Replace(component, "dapr.io/v1alpha1", "dapr.io/v2alpha1", 1),
+		},
+		{
+			name:  "other apiVersion",
+			apply: replaceAPIVersion("dapr.io/v1", "dapr.io/v2alpha1"),
+			doc:   component,
+		},
+		{
+			name:  "rename metadata",
+			apply: renameMetadata("maxRetries", "redisMaxRetries"),
+			doc:   component,
+			want:  strings.Replace(component, "name: maxRetries", "name: redisMaxRetries", 1),
+		},
+		{
+			name:  "rename metadata keeps comments",
+			apply: renameMetadata("redisHost", "host"),
+			doc:   component,
+			want:  strings.Replace(component, "name: redisHost", "name: host", 1),
+		},
+		{
+			name:  "metadata already renamed",
+			apply: renameMetadata("maxRetries", "redisHost"),
+			doc:   component,
+		},
+		{
+			name:  "no such metadata",
+			apply: renameMetadata("redisPassword", "password"),
+			doc:   component,
+		},
+		{
+			name:  "no metadata",
+			apply: renameMetadata("maxRetries", "redisMaxRetries"),
+			doc:   "kind: Component\nspec:\n  type: state.redis\n",
+		},
+		{
+			name:  "set default",
+			apply: setDefault([]string{"spec", "version"}, "v1"),
+			doc:   "kind: Component\nspec:\n  type: state.redis\n",
+			want:  "kind: Component\nspec:\n  type: state.redis\n  version: v1\n",
+		},
+		{
+			name:  "default already set",
+			apply: setDefault([]string{"spec", "version"}, "v1"),
+			doc:   "kind: Component\nspec:\n  version: v2\n",
+		},
+		{
+			name:  "no parent for the default",
+			apply: setDefault([]string{"spec", "version"}, "v1"),
+			doc:   "kind: Component\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			changed := tt.apply(doc.Content[0])
+			if changed != (tt.want != "") {
+				t.Fatalf("changed = %v, want %v", changed, tt.want != "")
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.doc
+			}
+			var buf bytes.Buffer
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2)
+			if err := enc.Encode(&doc); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("migrated to\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateFilesBetweenBundledReleases(t *testing.T) {
+	saved := migrations
+	migrations = []migration{
+		{Version: "v1.5.1", Kind: "Component", Type: "state.redis", Apply: renameMetadata("a", "b")},
+		{Version: "v1.6.0", Kind: "Component", Type: "state.redis", Apply: renameMetadata("b", "c")},
+		{Version: "v1.6.0", Kind: "Component", Type: "pubsub.redis", Apply: renameMetadata("b", "d")},
+		{Version: "v1.6.0", Kind: "Configuration", Apply: replaceAPIVersion("dapr.io/v1alpha1", "dapr.io/v2")},
+	}
+	defer func() { migrations = saved }()
+
+	dir := t.TempDir()
+	statestore := filepath.Join(dir, "components", "statestore.yaml")
+	config := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, statestore, "kind: Component\nspec:\n  type: state.redis\n  metadata:\n    - name: a\n")
+	writeTestFile(t, config, "apiVersion: dapr.io/v1alpha1\nkind: Configuration\n")
+
+	i := newTestInstaller(t, newFakeRuntime(), Options{InstallDir: dir})
+	got, err := i.migrateFiles("v1.5.0", "v1.6.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameStrings(got, []string{config, statestore}) {
+		t.Errorf("migrateFiles() = %v", got)
+	}
+	// The steps apply in order, and only to matching documents.
+	for p, want := range map[string]string{
+		statestore: "kind: Component\nspec:\n  type: state.redis\n  metadata:\n    - name: c\n",
+		config:     "apiVersion: dapr.io/v2\nkind: Configuration\n",
+	} {
+		if b, _ := os.ReadFile(p); string(b) != want {
+			t.Errorf("%s =\n%s\nwant\n%s", p, b, want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.6.0", "v1.6.0", 0},
+		{"v1.5.1", "v1.6.0", -1},
+		{"v1.10.0", "v1.9.2", 1},
+		{"1.6.0", "v1.6.0", 0},
+		{"v1.6.0-rc.1", "v1.6.0", -1},
+		{"v1.6.0-rc.2", "v1.6.0-rc.1", 1},
+		{"v2.0.0", "v1.99.99", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
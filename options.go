@@ -0,0 +1,187 @@
+package standalone
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Service is a container started by the installer.
+type Service string
+
+const (
+	// ServicePlacement is the Dapr actor placement service.
+	ServicePlacement Service = "placement"
+	// ServiceRedis is the Redis instance backing the default components.
+	ServiceRedis Service = "redis"
+	// ServiceZipkin is the Zipkin tracing server.
+	ServiceZipkin Service = "zipkin"
+)
+
+// AllServices lists every service the installer knows how to start.
+var AllServices = []Service{ServicePlacement, ServiceRedis, ServiceZipkin}
+
+// Component is a component file written to the components directory.
+type Component string
+
+const (
+	// ComponentStateStore is the Redis state store component.
+	ComponentStateStore Component = "statestore"
+	// ComponentPubSub is the Redis pub/sub component.
+	ComponentPubSub Component = "pubsub"
+)
+
+// AllComponents lists every component the installer knows how to create.
+var AllComponents = []Component{ComponentStateStore, ComponentPubSub}
+
+// DaprHomeEnv is the environment variable that overrides the default
+// install directory.
+const DaprHomeEnv = "DAPR_HOME"
+
+const (
+	defaultPlacementPort        = 50005
+	defaultPlacementPortWindows = 6050
+	defaultRedisPort            = 6379
+	defaultZipkinPort           = 9411
+)
+
+// Options configures an Installer. The zero value of every field
+// selects the default behavior.
+type Options struct {
+	// Version is the bundled Dapr release to install, e.g. "v1.6.0".
+	// Defaults to the default release of the installer.
+	Version string
+	// From is the installed version Upgrade upgrades from when the install
+	// directory has no install manifest, e.g. because an older installer
+	// created it. Defaults to the runtime version bin/dapr --version shows.
+	From string
+	// InstallDir is the root of the installation, holding bin, components
+	// and config.yaml. Defaults to $DAPR_HOME, or ~/.dapr.
+	InstallDir string
+	// Network is the Docker network the containers are attached to.
+	// When empty, the service ports are published on the host.
+	Network string
+	// PlacementPort is the host port of the placement service.
+	// Defaults to 50005, or 6050 on Windows.
+	PlacementPort int
+	// RedisPort is the host port of Redis. Defaults to 6379.
+	RedisPort int
+	// ZipkinPort is the host port of Zipkin. Defaults to 9411.
+	ZipkinPort int
+	// AutoPorts replaces host ports that are already in use with free
+	// ones instead of failing the install.
+	AutoPorts bool
+	// Services lists the containers to start. Defaults to AllServices.
+	Services []Service
+	// Components lists the component files to create. Defaults to AllComponents.
+	// Components are only created when Redis is one of the Services.
+	Components []Component
+	// ContainerRuntime loads the images and runs the containers.
+	// Defaults to the first of Docker, Podman and nerdctl found on the PATH.
+	ContainerRuntime ContainerRuntime
+	// ReadyTimeout is how long to wait for each service to accept
+	// connections after it was started. Defaults to 60 seconds.
+	ReadyTimeout time.Duration
+	// StepTimeout bounds each step of the install, e.g. extracting an
+	// archive, loading an image or starting the containers. Defaults to
+	// 10 minutes.
+	StepTimeout time.Duration
+	// Out receives the progress output of the default observer.
+	// Defaults to os.Stdout.
+	Out io.Writer
+	// Observer receives the progress events. Defaults to a console
+	// observer writing to Out.
+	Observer Observer
+}
+
+func (o *Options) setDefaults() error {
+	if o.InstallDir == "" {
+		o.InstallDir = os.Getenv(DaprHomeEnv)
+	}
+	if o.InstallDir == "" {
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			// e.g. HOME is not set in a CI container.
+			return fmt.Errorf("could not determine the install directory, set %s or the install directory explicitly: %w", DaprHomeEnv, err)
+		}
+		o.InstallDir = filepath.Join(homedir, ".dapr")
+	}
+	// The paths end up in config files and printed daprd flags, so they
+	// must not depend on the working directory.
+	installDir, err := filepath.Abs(o.InstallDir)
+	if err != nil {
+		return err
+	}
+	o.InstallDir = installDir
+	if o.PlacementPort == 0 {
+		o.PlacementPort = defaultPlacementPort
+		if runtime.GOOS == "windows" {
+			o.PlacementPort = defaultPlacementPortWindows
+		}
+	}
+	if o.RedisPort == 0 {
+		o.RedisPort = defaultRedisPort
+	}
+	if o.ZipkinPort == 0 {
+		o.ZipkinPort = defaultZipkinPort
+	}
+	if o.Services == nil {
+		o.Services = AllServices
+	}
+	if o.Components == nil {
+		o.Components = AllComponents
+	}
+	if o.ReadyTimeout == 0 {
+		o.ReadyTimeout = defaultReadyTimeout
+	}
+	if o.StepTimeout == 0 {
+		o.StepTimeout = defaultStepTimeout
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.Observer == nil {
+		o.Observer = NewConsoleObserver(o.Out)
+	}
+	return nil
+}
+
+func (o *Options) validate() error {
+	for _, port := range []int{o.PlacementPort, o.RedisPort, o.ZipkinPort} {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	for _, s := range o.Services {
+		if !containsService(AllServices, s) {
+			return fmt.Errorf("unknown service %q", s)
+		}
+	}
+	for _, c := range o.Components {
+		if !containsComponent(AllComponents, c) {
+			return fmt.Errorf("unknown component %q", c)
+		}
+	}
+	return nil
+}
+
+func containsService(services []Service, s Service) bool {
+	for _, v := range services {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsComponent(components []Component, c Component) bool {
+	for _, v := range components {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
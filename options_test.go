@@ -0,0 +1,71 @@
+package standalone
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSetDefaultsInstallDir(t *testing.T) {
+	home := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		installDir string
+		daprHome   string
+		// noHome unsets the home directory.
+		noHome  bool
+		want    string
+		wantErr bool
+	}{
+		{name: "DAPR_HOME empty", want: filepath.Join(home, ".dapr")},
+		{name: "DAPR_HOME", daprHome: filepath.Join(home, "sandbox"), want: filepath.Join(home, "sandbox")},
+		{name: "relative DAPR_HOME", daprHome: "sandbox", want: filepath.Join(wd, "sandbox")},
+		{name: "DAPR_HOME without home", daprHome: filepath.Join(home, "sandbox"), noHome: true, want: filepath.Join(home, "sandbox")},
+		{name: "install dir over DAPR_HOME", installDir: filepath.Join(home, "dir"), daprHome: filepath.Join(home, "sandbox"), want: filepath.Join(home, "dir")},
+		{name: "install dir without home", installDir: filepath.Join(home, "dir"), noHome: true, want: filepath.Join(home, "dir")},
+		{name: "no home", noHome: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(DaprHomeEnv, tt.daprHome)
+			homeDir := home
+			if tt.noHome {
+				homeDir = ""
+			}
+			// os.UserHomeDir reads USERPROFILE on Windows, home on Plan 9
+			// and HOME elsewhere.
+			switch runtime.GOOS {
+			case "windows":
+				t.Setenv("USERPROFILE", homeDir)
+			case "plan9":
+				t.Setenv("home", homeDir)
+			default:
+				t.Setenv("HOME", homeDir)
+			}
+
+			opts := Options{InstallDir: tt.installDir}
+			err := opts.setDefaults()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("setDefaults() chose %s, want an error", opts.InstallDir)
+				}
+				if !strings.Contains(err.Error(), DaprHomeEnv) {
+					t.Errorf("error %q does not mention %s", err, DaprHomeEnv)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.InstallDir != tt.want {
+				t.Errorf("InstallDir = %s, want %s", opts.InstallDir, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package standalone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Action is what Install does to a file, image, network or container.
+type Action string
+
+const (
+	ActionWrite   Action = "write"
+	ActionSkip    Action = "skip"
+	ActionExtract Action = "extract"
+	ActionReplace Action = "replace"
+	ActionLoad    Action = "load"
+	ActionCreate  Action = "create"
+	ActionRemove  Action = "remove"
+	ActionStart   Action = "start"
+	ActionKeep    Action = "keep"
+)
+
+// Plan describes what Install would change. It is returned by
+// Installer.Plan, which changes nothing.
+type Plan struct {
+	Version    string             `json:"version"`
+	InstallDir string             `json:"installDir"`
+	Runtime    string             `json:"runtime"`
+	Ports      map[Service]int    `json:"ports,omitempty"`
+	Files      []PlannedFile      `json:"files"`
+	Binaries   []PlannedBinary    `json:"binaries"`
+	Images     []PlannedImage     `json:"images"`
+	Network    *PlannedNetwork    `json:"network,omitempty"`
+	Containers []PlannedContainer `json:"containers"`
+}
+
+// PlannedFile is a file Install writes, replaces because an earlier install
+// wrote it for another address, or skips because it exists.
+type PlannedFile struct {
+	Path   string `json:"path"`
+	Action Action `json:"action"`
+}
+
+// PlannedBinary is an embedded archive extracted into Dir. The bin
+// directory is replaced as a whole.
+type PlannedBinary struct {
+	Asset  string `json:"asset"`
+	Dir    string `json:"dir"`
+	Action Action `json:"action"`
+}
+
+// PlannedImage is a bundled image loaded into the container runtime.
+type PlannedImage struct {
+	Service Service `json:"service"`
+	Image   string  `json:"image"`
+	// Present is true if an image with the same reference already exists.
+	Present bool   `json:"present"`
+	Action  Action `json:"action"`
+}
+
+// PlannedNetwork is the network the containers are attached to.
+type PlannedNetwork struct {
+	Name   string `json:"name"`
+	Action Action `json:"action"`
+}
+
+// PlannedContainer is a container Install removes, creates, starts or
+// keeps running.
+type PlannedContainer struct {
+	Service Service `json:"service"`
+	Name    string  `json:"name"`
+	Image   string  `json:"image,omitempty"`
+	Action  Action  `json:"action"`
+}
+
+// Plan returns what Install would change without changing anything. It
+// runs the same checks as Install and fails where Install would fail
+// before changing anything.
+func (i *Installer) Plan(ctx context.Context) (*Plan, error) {
+	if err := i.selectVersion(); err != nil {
+		return nil, err
+	}
+	if i.rtErr != nil {
+		return nil, i.rtErr
+	}
+
+	// Planning must not affect a later Install, nor report anything.
+	saved := i.opts
+	i.opts.Observer = ObserverFunc(func(Event) {})
+	defer func() {
+		i.opts = saved
+	}()
+
+	if err := i.prepare(ctx); err != nil {
+		return nil, err
+	}
+	if err := i.preflightPorts(ctx); err != nil {
+		return nil, err
+	}
+
+	p := &Plan{
+		Version:    i.opts.Version,
+		InstallDir: i.opts.InstallDir,
+		Runtime:    i.rt.Name(),
+	}
+	if i.opts.Network == "" {
+		p.Ports = map[Service]int{}
+		for _, s := range AllServices {
+			if i.hasService(s) {
+				_, p.Ports[s] = i.serviceAddress(s)
+			}
+		}
+	}
+
+	previous, err := ReadManifest(i.opts.InstallDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range i.configFiles() {
+		b, err := f.content()
+		if err != nil {
+			return nil, err
+		}
+		action, _, err := i.configAction(previous, f.path, b)
+		if err != nil {
+			return nil, err
+		}
+		p.Files = append(p.Files, PlannedFile{Path: f.path, Action: action})
+	}
+	p.Files = append(p.Files, PlannedFile{
+		Path:   filepath.Join(i.opts.InstallDir, ManifestFileName),
+		Action: ActionWrite,
+	})
+
+	versionBinDir := filepath.Join(i.versionDir(i.opts.Version), "bin")
+	binAction := ActionExtract
+	if _, err := os.Stat(versionBinDir); err == nil {
+		binAction = ActionReplace
+	}
+	cli, err := i.bundle.cliAsset()
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range append([]bundleAsset{cli}, i.bundle.binaryAssets()...) {
+		p.Binaries = append(p.Binaries, PlannedBinary{
+			Asset:  path.Base(asset.Path),
+			Dir:    filepath.Join(versionBinDir, filepath.FromSlash(asset.Dir)),
+			Action: binAction,
+		})
+	}
+
+	images, err := i.rt.Images(ctx)
+	if err != nil {
+		return nil, err
+	}
+	present := newImageSet(images)
+	for _, img := range i.bundle.Images {
+		if !i.hasService(img.Role) {
+			continue
+		}
+		p.Images = append(p.Images, PlannedImage{
+			Service: img.Role,
+			Image:   img.Image,
+			Present: present.contains(img.Image),
+			Action:  ActionLoad,
+		})
+	}
+
+	if network := i.opts.Network; network != "" {
+		exists, err := i.rt.NetworkExists(ctx, network)
+		if err != nil {
+			return nil, err
+		}
+		action := ActionCreate
+		if exists {
+			action = ActionKeep
+		}
+		p.Network = &PlannedNetwork{Name: network, Action: action}
+	}
+
+	if p.Containers, err = i.planContainers(ctx); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// planContainers mirrors startServices. The placement container is always
+// replaced while the other services reuse an existing container unless it
+// is outdated.
+func (i *Installer) planContainers(ctx context.Context) ([]PlannedContainer, error) {
+	var planned []PlannedContainer
+	for _, s := range AllServices {
+		if !i.hasService(s) {
+			continue
+		}
+		container := createContainerName(serviceContainers[s], i.opts.Network)
+		img, err := i.bundle.image(s)
+		if err != nil {
+			return nil, err
+		}
+		info, err := i.rt.Inspect(ctx, container)
+		if err != nil {
+			return nil, err
+		}
+
+		if s == ServicePlacement {
+			backup, err := i.rt.Inspect(ctx, container+"_previous")
+			if err != nil {
+				return nil, err
+			}
+			if backup.Exists {
+				planned = append(planned, PlannedContainer{Service: s, Name: container + "_previous", Action: ActionRemove})
+			}
+			if info.Exists {
+				planned = append(planned, PlannedContainer{Service: s, Name: container, Image: info.Image, Action: ActionRemove})
+			}
+			planned = append(planned, PlannedContainer{Service: s, Name: container, Image: img.Image, Action: ActionCreate})
+			continue
+		}
+
+		switch {
+		case !info.Exists:
+			planned = append(planned, PlannedContainer{Service: s, Name: container, Image: img.Image, Action: ActionCreate})
+		case i.outdated(info, s):
+			planned = append(planned, PlannedContainer{Service: s, Name: container, Image: info.Image, Action: ActionRemove})
+			planned = append(planned, PlannedContainer{Service: s, Name: container, Image: img.Image, Action: ActionCreate})
+		case !info.Running:
+			planned = append(planned, PlannedContainer{Service: s, Name: container, Image: info.Image, Action: ActionStart})
+		default:
+			planned = append(planned, PlannedContainer{Service: s, Name: container, Image: info.Image, Action: ActionKeep})
+		}
+	}
+	return planned, nil
+}
+
+// WriteText writes the plan in a human readable form.
+func (p *Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Installing Dapr %s to %s would:\n", p.Version, p.InstallDir)
+
+	fmt.Fprintln(&b, "\nFiles:")
+	for _, f := range p.Files {
+		switch f.Action {
+		case ActionSkip:
+			fmt.Fprintf(&b, "  • skip %s (exists)\n", f.Path)
+		default:
+			fmt.Fprintf(&b, "  • %s %s\n", f.Action, f.Path)
+		}
+	}
+
+	fmt.Fprintln(&b, "\nBinaries:")
+	for _, bin := range p.Binaries {
+		fmt.Fprintf(&b, "  • %s %s to %s\n", bin.Action, bin.Asset, bin.Dir)
+	}
+
+	fmt.Fprintf(&b, "\n%s images:\n", p.Runtime)
+	for _, img := range p.Images {
+		if img.Present {
+			fmt.Fprintf(&b, "  • %s %s (present)\n", img.Action, img.Image)
+		} else {
+			fmt.Fprintf(&b, "  • %s %s\n", img.Action, img.Image)
+		}
+	}
+
+	if p.Network != nil {
+		fmt.Fprintln(&b, "\nNetwork:")
+		fmt.Fprintf(&b, "  • %s %s\n", p.Network.Action, p.Network.Name)
+	}
+
+	fmt.Fprintf(&b, "\n%s containers:\n", p.Runtime)
+	for _, c := range p.Containers {
+		port := ""
+		if c.Action == ActionCreate && p.Ports[c.Service] != 0 {
+			port = fmt.Sprintf(" on port %d", p.Ports[c.Service])
+		}
+		fmt.Fprintf(&b, "  • %s %s (%s)%s\n", c.Action, c.Name, c.Image, port)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
@@ -0,0 +1,132 @@
+package standalone
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	useTestBundle(t, "v1.6.0", "v1.5.1")
+	placement := createContainerName(DaprPlacementContainerName, "n")
+	redis := createContainerName(DaprRedisContainerName, "n")
+	zipkin := createContainerName(DaprZipkinContainerName, "n")
+	tests := []struct {
+		name string
+		// installed is the version installed before planning, "" for none.
+		installed      string
+		wantFiles      map[string]Action
+		wantBinaries   Action
+		wantNetwork    Action
+		wantContainers []PlannedContainer
+	}{
+		{
+			name: "fresh install",
+			wantFiles: map[string]Action{
+				"config.yaml":                ActionWrite,
+				"components/statestore.yaml": ActionWrite,
+				"components/pubsub.yaml":     ActionWrite,
+				ManifestFileName:             ActionWrite,
+			},
+			wantBinaries: ActionExtract,
+			wantNetwork:  ActionCreate,
+			wantContainers: []PlannedContainer{
+				{Service: ServicePlacement, Name: placement, Image: testImage(ServicePlacement, "v1.6.0"), Action: ActionCreate},
+				{Service: ServiceRedis, Name: redis, Image: testImage(ServiceRedis, "v1.6.0"), Action: ActionCreate},
+				{Service: ServiceZipkin, Name: zipkin, Image: testImage(ServiceZipkin, "v1.6.0"), Action: ActionCreate},
+			},
+		},
+		{
+			name:      "upgrade",
+			installed: "v1.5.1",
+			wantFiles: map[string]Action{
+				"config.yaml":                ActionSkip,
+				"components/statestore.yaml": ActionSkip,
+				"components/pubsub.yaml":     ActionSkip,
+				ManifestFileName:             ActionWrite,
+			},
+			wantBinaries: ActionExtract,
+			wantNetwork:  ActionKeep,
+			wantContainers: []PlannedContainer{
+				{Service: ServicePlacement, Name: placement, Image: testImage(ServicePlacement, "v1.5.1"), Action: ActionRemove},
+				{Service: ServicePlacement, Name: placement, Image: testImage(ServicePlacement, "v1.6.0"), Action: ActionCreate},
+				{Service: ServiceRedis, Name: redis, Image: testImage(ServiceRedis, "v1.5.1"), Action: ActionKeep},
+				{Service: ServiceZipkin, Name: zipkin, Image: testImage(ServiceZipkin, "v1.5.1"), Action: ActionKeep},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rt := newFakeRuntime()
+			if tt.installed != "" {
+				rt.queueLoads(tt.installed, AllServices...)
+				i := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", Version: tt.installed})
+				if err := i.Install(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			files, containers, calls := snapshotFiles(t, dir), snapshotRuntime(rt), len(rt.calls)
+
+			var events []Event
+			i := newTestInstaller(t, rt, Options{
+				InstallDir: dir,
+				Network:    "n",
+				Observer:   ObserverFunc(func(e Event) { events = append(events, e) }),
+			})
+			plan, err := i.Plan(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if plan.Version != "v1.6.0" || plan.Runtime != "fake" || plan.Ports != nil {
+				t.Errorf("plan = %s on %s with ports %v, want v1.6.0 on fake without ports", plan.Version, plan.Runtime, plan.Ports)
+			}
+			if len(plan.Files) != len(tt.wantFiles) {
+				t.Errorf("planned %d files, want %d", len(plan.Files), len(tt.wantFiles))
+			}
+			for _, f := range plan.Files {
+				rel, _ := filepath.Rel(dir, f.Path)
+				if want := tt.wantFiles[filepath.ToSlash(rel)]; f.Action != want {
+					t.Errorf("planned %s %s, want %s", f.Action, rel, want)
+				}
+			}
+			if len(plan.Binaries) == 0 {
+				t.Error("no binaries planned")
+			}
+			for _, b := range plan.Binaries {
+				if b.Action != tt.wantBinaries || !filepath.IsAbs(b.Dir) {
+					t.Errorf("planned %s %s to %s, want %s", b.Action, b.Asset, b.Dir, tt.wantBinaries)
+				}
+			}
+			var images []PlannedImage
+			for _, s := range AllServices {
+				images = append(images, PlannedImage{Service: s, Image: testImage(s, "v1.6.0"), Action: ActionLoad})
+			}
+			if !reflect.DeepEqual(plan.Images, images) {
+				t.Errorf("planned images %+v, want %+v", plan.Images, images)
+			}
+			if want := (&PlannedNetwork{Name: "n", Action: tt.wantNetwork}); !reflect.DeepEqual(plan.Network, want) {
+				t.Errorf("planned network %+v, want %+v", plan.Network, want)
+			}
+			if !reflect.DeepEqual(plan.Containers, tt.wantContainers) {
+				t.Errorf("planned containers %+v, want %+v", plan.Containers, tt.wantContainers)
+			}
+
+			// Planning only inspects.
+			if got := snapshotFiles(t, dir); !reflect.DeepEqual(got, files) {
+				t.Errorf("files changed by Plan:\n%v\nwant\n%v", got, files)
+			}
+			if got := snapshotRuntime(rt); got != containers {
+				t.Errorf("runtime changed by Plan:\n%s\nwant\n%s", got, containers)
+			}
+			if got := rt.calls[calls:]; len(got) != 0 {
+				t.Errorf("Plan called %q", got)
+			}
+			if len(events) != 0 {
+				t.Errorf("Plan reported %+v", events)
+			}
+		})
+	}
+}
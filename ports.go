@@ -0,0 +1,127 @@
+package standalone
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// maxPortSearch is how many ports above the requested one are tried when
+// looking for a free port.
+const maxPortSearch = 100
+
+// PortConflictError is returned by the preflight check when the host port
+// of a service is already bound.
+type PortConflictError struct {
+	Service Service
+	Port    int
+	// Suggested is a free port that could be used instead, or 0 if none was found.
+	Suggested int
+}
+
+func (e *PortConflictError) Error() string {
+	msg := fmt.Sprintf("port %d for %s is already in use", e.Port, e.Service)
+	if e.Suggested != 0 {
+		msg += fmt.Sprintf(", port %d is free", e.Suggested)
+	}
+	return msg
+}
+
+func (e *PortConflictError) Unwrap() error {
+	return ErrPortAllocated
+}
+
+// containerPorts are the ports the services listen on in their containers.
+var containerPorts = map[Service]int{
+	ServicePlacement: placementContainerPort,
+	ServiceRedis:     redisContainerPort,
+	ServiceZipkin:    zipkinContainerPort,
+}
+
+// preflightPorts checks that the host ports of the services are free.
+// Busy ports are replaced by free ones if Options.AutoPorts is set.
+// A port held by the container of a previous install is not a conflict
+// since that container is reused or replaced.
+func (i *Installer) preflightPorts(ctx context.Context) error {
+	if i.opts.Network != "" {
+		// Nothing is published on the host.
+		return nil
+	}
+
+	type servicePort struct {
+		service   Service
+		container string
+		port      *int
+	}
+	ports := []servicePort{
+		{ServicePlacement, DaprPlacementContainerName, &i.opts.PlacementPort},
+		{ServiceRedis, DaprRedisContainerName, &i.opts.RedisPort},
+		{ServiceZipkin, DaprZipkinContainerName, &i.opts.ZipkinPort},
+	}
+
+	taken := map[int]bool{}
+	for _, sp := range ports {
+		if i.hasService(sp.service) {
+			taken[*sp.port] = true
+		}
+	}
+
+	for _, sp := range ports {
+		if !i.hasService(sp.service) {
+			continue
+		}
+		info, err := i.rt.Inspect(ctx, sp.container)
+		if err != nil {
+			return err
+		}
+		if info.Exists && i.publishesPort(info, sp.service) || portAvailable(*sp.port) {
+			continue
+		}
+
+		suggested := findFreePort(*sp.port+1, taken)
+		if !i.opts.AutoPorts || suggested == 0 {
+			return &PortConflictError{Service: sp.service, Port: *sp.port, Suggested: suggested}
+		}
+		i.message("Port %d for %s is already in use, using port %d", *sp.port, sp.service, suggested)
+		*sp.port = suggested
+		taken[suggested] = true
+	}
+
+	return nil
+}
+
+// publishesPort reports whether the container of s publishes the host port
+// chosen for s. Nothing is published on a network.
+func (i *Installer) publishesPort(info ContainerInfo, s Service) bool {
+	if i.opts.Network != "" {
+		return true
+	}
+	_, port := i.serviceAddress(s)
+	for _, p := range info.Ports {
+		if p.HostPort == port && p.ContainerPort == containerPorts[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// portAvailable reports whether the TCP port can be bound on all interfaces.
+func portAvailable(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// findFreePort returns the first available port from start that is not
+// taken, or 0 if there is none within maxPortSearch ports.
+func findFreePort(start int, taken map[int]bool) int {
+	for port := start; port < start+maxPortSearch && port <= 65535; port++ {
+		if !taken[port] && portAvailable(port) {
+			return port
+		}
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreflightPorts(t *testing.T) {
+	busy := listenTestPort(t)
+	other := freeTestPort(t)
+	tests := []struct {
+		name      string
+		port      int
+		autoPorts bool
+		// existing is the redis container of a previous install, if any.
+		existing *ContainerInfo
+		// wantConflict is true if the preflight fails with a conflict.
+		wantConflict bool
+		// wantMoved is true if AutoPorts picked another port.
+		wantMoved bool
+	}{
+		{name: "free", port: other},
+		{name: "conflict", port: busy, wantConflict: true},
+		{name: "auto ports", port: busy, autoPorts: true, wantMoved: true},
+		{
+			name:     "held by the previous container",
+			port:     busy,
+			existing: &ContainerInfo{Exists: true, Running: true, Ports: []PortBinding{{HostPort: busy, ContainerPort: redisContainerPort}}},
+		},
+		{
+			name:         "previous container on another port",
+			port:         busy,
+			existing:     &ContainerInfo{Exists: true, Running: true, Ports: []PortBinding{{HostPort: other, ContainerPort: redisContainerPort}}},
+			wantConflict: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newFakeRuntime()
+			if tt.existing != nil {
+				rt.containers[DaprRedisContainerName] = *tt.existing
+			}
+			var messages []string
+			i := newTestInstaller(t, rt, Options{
+				InstallDir: t.TempDir(),
+				Services:   []Service{ServiceRedis},
+				RedisPort:  tt.port,
+				AutoPorts:  tt.autoPorts,
+				Observer:   ObserverFunc(func(e Event) { messages = append(messages, e.Message) }),
+			})
+
+			err := i.preflightPorts(context.Background())
+			var conflict *PortConflictError
+			if tt.wantConflict {
+				if !errors.As(err, &conflict) || !errors.Is(err, ErrPortAllocated) {
+					t.Fatalf("preflightPorts() error = %v, want a PortConflictError", err)
+				}
+				if conflict.Service != ServiceRedis || conflict.Port != tt.port || conflict.Suggested <= tt.port {
+					t.Errorf("PortConflictError = %+v", conflict)
+				}
+				// The message suggests the free port.
+				if want := fmt.Sprintf("port %d is free", conflict.Suggested); !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := i.opts.RedisPort
+			if !tt.wantMoved {
+				if got != tt.port {
+					t.Errorf("RedisPort = %d, want %d", got, tt.port)
+				}
+				return
+			}
+			if got == tt.port || !portAvailable(got) {
+				t.Errorf("RedisPort = %d, want a free port other than %d", got, tt.port)
+			}
+			if want := fmt.Sprintf("using port %d", got); len(messages) != 1 || !strings.Contains(messages[0], want) {
+				t.Errorf("messages = %q, want one containing %q", messages, want)
+			}
+		})
+	}
+}
+
+func TestInstallRecreatesContainerOnNewPort(t *testing.T) {
+	useTestBundle(t, "v1.6.0")
+	stubProbes(t)
+	dir := t.TempDir()
+	rt := newFakeRuntime()
+	first, second := freeTestPort(t), freeTestPort(t)
+	for second == first {
+		second = freeTestPort(t)
+	}
+	for _, port := range []int{first, second} {
+		rt.queueLoads("v1.6.0", ServiceRedis)
+		i := newTestInstaller(t, rt, Options{InstallDir: dir, Services: []Service{ServiceRedis}, RedisPort: port})
+		if err := i.Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		info := rt.containers[DaprRedisContainerName]
+		if want := []PortBinding{{HostPort: port, ContainerPort: redisContainerPort}}; !reflect.DeepEqual(info.Ports, want) {
+			t.Errorf("redis publishes %v, want %v", info.Ports, want)
+		}
+		m, err := ReadManifest(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Ports[ServiceRedis] != port {
+			t.Errorf("manifest port = %d, want %d", m.Ports[ServiceRedis], port)
+		}
+	}
+	if len(rt.containers) != 1 {
+		t.Errorf("containers = %v, want only the new redis", rt.containers)
+	}
+}
+
+// listenTestPort returns a port that is bound for the duration of the test.
+func listenTestPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// freeTestPort returns a port that was free when it was picked.
+func freeTestPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// stubProbes makes every service ready as soon as its container runs.
+func stubProbes(t *testing.T) {
+	t.Helper()
+	saved := serviceProbes
+	serviceProbes = map[Service]probe{}
+	for s := range saved {
+		serviceProbes[s] = func(context.Context, string) error { return nil }
+	}
+	t.Cleanup(func() { serviceProbes = saved })
+}
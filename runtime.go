@@ -0,0 +1,371 @@
+package standalone
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ErrNoContainerRuntime is returned when no supported container runtime
+// is installed.
+var ErrNoContainerRuntime = errors.New("no container runtime found")
+
+// ContainerRuntime loads images and manages the service containers.
+type ContainerRuntime interface {
+	// Name returns the name of the runtime, e.g. "docker".
+	Name() string
+	// Load loads an image archive and returns the names of the loaded images.
+	// The output of the runtime is written to out, possibly from several
+	// goroutines.
+	Load(ctx context.Context, in io.Reader, out io.Writer) ([]string, error)
+	// Images returns the names of the images known to the runtime.
+	Images(ctx context.Context) (map[string]bool, error)
+	// RemoveImage removes an image.
+	RemoveImage(ctx context.Context, image string) error
+	// ImageID returns the ID of an image, i.e. the digest of its config,
+	// which unlike its repo digests is preserved by save and load.
+	ImageID(ctx context.Context, image string) (string, error)
+	// Run creates and starts a container.
+	Run(ctx context.Context, spec ContainerSpec) error
+	// Start starts an existing container.
+	Start(ctx context.Context, name string) error
+	// Stop stops a running container.
+	Stop(ctx context.Context, name string) error
+	// Rename renames a container.
+	Rename(ctx context.Context, name, newName string) error
+	// Remove forcibly removes a container.
+	Remove(ctx context.Context, name string) error
+	// Inspect returns the state of a container. A container that does not
+	// exist is not an error; its Exists field is false.
+	Inspect(ctx context.Context, name string) (ContainerInfo, error)
+	// NetworkExists reports whether a network exists.
+	NetworkExists(ctx context.Context, name string) (bool, error)
+	// CreateNetwork creates a bridge network.
+	CreateNetwork(ctx context.Context, name string) error
+	// RemoveNetwork removes a network.
+	RemoveNetwork(ctx context.Context, name string) error
+}
+
+// ContainerSpec describes a container to run.
+type ContainerSpec struct {
+	Name  string
+	Image string
+	// Entrypoint overrides the entrypoint of the image.
+	Entrypoint string
+	// Network is the network to attach the container to.
+	Network string
+	// NetworkAlias is the name of the container on Network.
+	NetworkAlias string
+	// Ports maps host ports to container ports.
+	Ports []PortBinding
+	// Restart is the restart policy, e.g. "always".
+	Restart string
+}
+
+// PortBinding publishes a container port on the host.
+type PortBinding struct {
+	HostPort      int
+	ContainerPort int
+}
+
+// ContainerInfo is the state of a container.
+type ContainerInfo struct {
+	Exists     bool
+	Running    bool
+	Restarting bool
+	// RestartCount is how often the restart policy restarted the container.
+	RestartCount int
+	Image        string
+	// Ports are the ports the container publishes on the host.
+	Ports []PortBinding
+}
+
+const (
+	// RuntimeDocker is the Docker CLI.
+	RuntimeDocker = "docker"
+	// RuntimePodman is the Podman CLI.
+	RuntimePodman = "podman"
+	// RuntimeNerdctl is the containerd nerdctl CLI.
+	RuntimeNerdctl = "nerdctl"
+)
+
+// ContainerRuntimes lists the supported CLI runtimes in order of detection preference.
+var ContainerRuntimes = []string{RuntimeDocker, RuntimePodman, RuntimeNerdctl}
+
+// NewContainerRuntime returns the runtime with the given name, one of
+// ContainerRuntimes or RuntimeDockerEngine.
+func NewContainerRuntime(name string) (ContainerRuntime, error) {
+	if name == RuntimeDockerEngine {
+		return NewEngineRuntime("")
+	}
+	for _, r := range ContainerRuntimes {
+		if r == name {
+			return &cliRuntime{binary: name}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown container runtime %q", name)
+}
+
+// DetectContainerRuntime returns the first supported runtime whose CLI
+// is found on the PATH. Without a CLI, the Docker Engine API is used if
+// DOCKER_HOST is set or the default socket exists.
+func DetectContainerRuntime() (ContainerRuntime, error) {
+	for _, name := range ContainerRuntimes {
+		if _, err := exec.LookPath(name); err == nil {
+			return &cliRuntime{binary: name}, nil
+		}
+	}
+	if os.Getenv("DOCKER_HOST") != "" {
+		return NewEngineRuntime("")
+	}
+	if _, err := os.Stat(strings.TrimPrefix(defaultDockerHost, "unix://")); err == nil {
+		return NewEngineRuntime(defaultDockerHost)
+	}
+	return nil, fmt.Errorf("%w, install one of %s", ErrNoContainerRuntime, strings.Join(ContainerRuntimes, ", "))
+}
+
+// cliRuntime drives a Docker-compatible CLI. Docker, Podman and nerdctl
+// accept the same commands and flags for everything the installer needs.
+type cliRuntime struct {
+	binary string
+}
+
+func (r *cliRuntime) Name() string {
+	return r.binary
+}
+
+func (r *cliRuntime) Load(ctx context.Context, in io.Reader, out io.Writer) ([]string, error) {
+	subProcess := exec.CommandContext(ctx, r.binary, "load")
+
+	stdin, err := subProcess.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("an error occured: %w", err)
+	}
+	defer stdin.Close()
+
+	var stdout bytes.Buffer
+	subProcess.Stdout = io.MultiWriter(out, &stdout)
+	subProcess.Stderr = out
+
+	if err = subProcess.Start(); err != nil {
+		return nil, fmt.Errorf("an error occured: %w", err)
+	}
+
+	if _, err = io.Copy(stdin, contextReader{ctx, in}); err != nil {
+		// Do not leave the child process behind.
+		_ = subProcess.Process.Kill()
+		_ = subProcess.Wait()
+		return nil, fmt.Errorf("an error occured: %w", err)
+	}
+
+	stdin.Close()
+
+	if err = subProcess.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("an error occured: %w", err)
+	}
+
+	return parseLoadedImages(stdout.String()), nil
+}
+
+func (r *cliRuntime) Images(ctx context.Context) (map[string]bool, error) {
+	response, err := RunCmdAndWaitContext(ctx, r.binary, "images", "--format", "{{.Repository}}:{{.Tag}}")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list images: %w", err)
+	}
+	images := map[string]bool{}
+	for _, line := range strings.Split(response, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			images[line] = true
+		}
+	}
+	return images, nil
+}
+
+func (r *cliRuntime) RemoveImage(ctx context.Context, image string) error {
+	_, err := RunCmdAndWaitContext(ctx, r.binary, "rmi", image)
+	return err
+}
+
+func (r *cliRuntime) ImageID(ctx context.Context, image string) (string, error) {
+	response, err := RunCmdAndWaitContext(ctx, r.binary, "image", "inspect", "--format", "{{.Id}}", image)
+	if err != nil {
+		return "", fmt.Errorf("unable to inspect image %s: %w", image, err)
+	}
+	return strings.TrimSpace(response), nil
+}
+
+func (r *cliRuntime) Run(ctx context.Context, spec ContainerSpec) error {
+	args := []string{
+		"run",
+		"--name", spec.Name,
+		"-d",
+	}
+	if spec.Restart != "" {
+		args = append(args, "--restart", spec.Restart)
+	}
+	if spec.Entrypoint != "" {
+		args = append(args, "--entrypoint", spec.Entrypoint)
+	}
+	if spec.Network != "" {
+		args = append(args, "--network", spec.Network)
+		if spec.NetworkAlias != "" {
+			args = append(args, "--network-alias", spec.NetworkAlias)
+		}
+	}
+	for _, p := range spec.Ports {
+		args = append(args, "-p", fmt.Sprintf("%d:%d", p.HostPort, p.ContainerPort))
+	}
+	args = append(args, spec.Image)
+
+	_, err := RunCmdAndWaitContext(ctx, r.binary, args...)
+	return classifyCLIError(err)
+}
+
+func (r *cliRuntime) Start(ctx context.Context, name string) error {
+	_, err := RunCmdAndWaitContext(ctx, r.binary, "start", name)
+	return classifyCLIError(err)
+}
+
+func (r *cliRuntime) Stop(ctx context.Context, name string) error {
+	_, err := RunCmdAndWaitContext(ctx, r.binary, "stop", name)
+	return err
+}
+
+func (r *cliRuntime) Rename(ctx context.Context, name, newName string) error {
+	_, err := RunCmdAndWaitContext(ctx, r.binary, "rename", name, newName)
+	return err
+}
+
+func (r *cliRuntime) Remove(ctx context.Context, name string) error {
+	_, err := RunCmdAndWaitContext(ctx, r.binary, "rm", "--force", name)
+	return err
+}
+
+func (r *cliRuntime) Inspect(ctx context.Context, name string) (ContainerInfo, error) {
+	// e.g. docker ps --all --filter name=dapr_redis --format {{.Names}}
+	response, err := RunCmdAndWaitContext(ctx, r.binary, "ps", "--all", "--filter", "name="+name, "--format", "{{.Names}}")
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("unable to confirm whether %s is running or exists. error\n%v", name, err.Error())
+	}
+	// The name filter matches substrings, so look for an exact match.
+	found := false
+	for _, line := range strings.Split(response, "\n") {
+		if strings.TrimSpace(line) == name {
+			found = true
+		}
+	}
+	if !found {
+		return ContainerInfo{}, nil
+	}
+
+	// The port bindings follow as host:container/protocol, e.g. 6379:6379/tcp.
+	response, err = RunCmdAndWaitContext(ctx, r.binary, "inspect", "--format",
+		"{{.State.Running}} {{.State.Restarting}} {{.RestartCount}} {{.Config.Image}}"+
+			"{{range $p, $b := .HostConfig.PortBindings}}{{range $b}} {{.HostPort}}:{{$p}}{{end}}{{end}}", name)
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("unable to inspect container %s: %w", name, err)
+	}
+	fields := strings.Fields(response)
+	if len(fields) < 4 {
+		return ContainerInfo{}, errors.New("unexpected inspect output: " + response)
+	}
+	restartCount, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return ContainerInfo{}, errors.New("unexpected inspect output: " + response)
+	}
+	info := ContainerInfo{
+		Exists:       true,
+		Running:      fields[0] == "true",
+		Restarting:   fields[1] == "true",
+		RestartCount: restartCount,
+		Image:        fields[3],
+	}
+	for _, binding := range fields[4:] {
+		idx := strings.Index(binding, ":")
+		if idx < 0 {
+			return ContainerInfo{}, errors.New("unexpected inspect output: " + response)
+		}
+		if p, ok := parsePortBinding(binding[:idx], binding[idx+1:]); ok {
+			info.Ports = append(info.Ports, p)
+		}
+	}
+	return info, nil
+}
+
+func (r *cliRuntime) NetworkExists(ctx context.Context, name string) (bool, error) {
+	response, err := RunCmdAndWaitContext(ctx, r.binary, "network", "ls", "--format", "{{.Name}}")
+	if err != nil {
+		return false, fmt.Errorf("unable to list networks: %w", classifyCLIError(err))
+	}
+	for _, line := range strings.Split(response, "\n") {
+		if strings.TrimSpace(line) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *cliRuntime) CreateNetwork(ctx context.Context, name string) error {
+	_, err := RunCmdAndWaitContext(ctx, r.binary, "network", "create", name)
+	return classifyCLIError(err)
+}
+
+func (r *cliRuntime) RemoveNetwork(ctx context.Context, name string) error {
+	_, err := RunCmdAndWaitContext(ctx, r.binary, "network", "rm", name)
+	return err
+}
+
+// classifyCLIError wraps err with a typed error if the message printed by
+// the CLI is recognized.
+func classifyCLIError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if typed := classifyErrorMessage(err.Error()); typed != nil {
+		return fmt.Errorf("%w: %v", typed, err)
+	}
+	return err
+}
+
+// parsePortBinding parses a host port and a container port such as
+// "6379/tcp". Bindings to a random host port are skipped.
+func parsePortBinding(hostPort, containerPort string) (PortBinding, bool) {
+	host, err := strconv.Atoi(hostPort)
+	if err != nil {
+		return PortBinding{}, false
+	}
+	if idx := strings.Index(containerPort, "/"); idx >= 0 {
+		containerPort = containerPort[:idx]
+	}
+	port, err := strconv.Atoi(containerPort)
+	if err != nil {
+		return PortBinding{}, false
+	}
+	return PortBinding{HostPort: host, ContainerPort: port}, true
+}
+
+// parseLoadedImages extracts image names from the output of docker load,
+// e.g. "Loaded image: redis:latest".
+func parseLoadedImages(output string) []string {
+	var loaded []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		for _, prefix := range []string{"Loaded image: ", "Loaded image(s): ", "Loaded image ID: "} {
+			if strings.HasPrefix(line, prefix) {
+				for _, image := range strings.Split(strings.TrimPrefix(line, prefix), ",") {
+					loaded = append(loaded, strings.TrimSpace(image))
+				}
+			}
+		}
+	}
+	return loaded
+}
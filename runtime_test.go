@@ -0,0 +1,132 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeCLI is a shell script that logs its arguments, one per line and
+// followed by "--", and answers ps and inspect for a running redis.
+const fakeCLI = `#!/bin/sh
+for arg; do echo "$arg"; done >> "$0.log"
+echo -- >> "$0.log"
+case "$1" in
+ps) echo "${4#name=}" ;;
+inspect) echo "true false 2 redis:6 6380:6379/tcp" ;;
+esac
+`
+
+// cliArgs returns the argument vectors the fake CLI at p was called with.
+func cliArgs(t *testing.T, p string) [][]string {
+	t.Helper()
+	b, err := os.ReadFile(p + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls [][]string
+	var args []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		if line == "--" {
+			calls = append(calls, args)
+			args = nil
+			continue
+		}
+		args = append(args, line)
+	}
+	return calls
+}
+
+func TestDetectContainerRuntime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake CLIs are shell scripts")
+	}
+	tests := []struct {
+		name       string
+		clis       []string
+		dockerHost string
+		want       string
+		wantErr    error
+	}{
+		{name: "podman", clis: []string{RuntimePodman}, want: RuntimePodman},
+		{name: "nerdctl", clis: []string{RuntimeNerdctl}, want: RuntimeNerdctl},
+		{name: "podman before nerdctl", clis: []string{RuntimeNerdctl, RuntimePodman}, want: RuntimePodman},
+		{name: "docker before podman", clis: []string{RuntimePodman, RuntimeDocker}, want: RuntimeDocker},
+		{name: "CLI before DOCKER_HOST", clis: []string{RuntimeNerdctl}, dockerHost: "tcp://127.0.0.1:2375", want: RuntimeNerdctl},
+		{name: "DOCKER_HOST", dockerHost: "tcp://127.0.0.1:2375", want: RuntimeDockerEngine},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.clis {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(fakeCLI), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("PATH", dir)
+			t.Setenv("DOCKER_HOST", tt.dockerHost)
+
+			rt, err := DetectContainerRuntime()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == RuntimeDockerEngine {
+				// It is named after the CLI its commands are shown for.
+				if _, ok := rt.(*engineRuntime); !ok {
+					t.Errorf("DetectContainerRuntime() = %T, want the Docker Engine API", rt)
+				}
+				return
+			}
+			if rt.Name() != tt.want {
+				t.Fatalf("DetectContainerRuntime() = %s, want %s", rt.Name(), tt.want)
+			}
+
+			ctx := context.Background()
+			err = rt.Run(ctx, ContainerSpec{
+				Name:         "dapr_redis_n",
+				Image:        "redis:6",
+				Network:      "n",
+				NetworkAlias: "dapr_redis",
+				Ports:        []PortBinding{{HostPort: 6380, ContainerPort: 6379}},
+				Restart:      "always",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := rt.Inspect(ctx, "dapr_redis_n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantInfo := ContainerInfo{Exists: true, Running: true, RestartCount: 2, Image: "redis:6", Ports: []PortBinding{{HostPort: 6380, ContainerPort: 6379}}}
+			if !reflect.DeepEqual(info, wantInfo) {
+				t.Errorf("Inspect() = %+v, want %+v", info, wantInfo)
+			}
+
+			want := [][]string{
+				{"run", "--name", "dapr_redis_n", "-d", "--restart", "always", "--network", "n", "--network-alias", "dapr_redis", "-p", "6380:6379", "redis:6"},
+				{"ps", "--all", "--filter", "name=dapr_redis_n", "--format", "{{.Names}}"},
+				{"inspect", "--format", "{{.State.Running}} {{.State.Restarting}} {{.RestartCount}} {{.Config.Image}}" +
+					"{{range $p, $b := .HostConfig.PortBindings}}{{range $b}} {{.HostPort}}:{{$p}}{{end}}{{end}}", "dapr_redis_n"},
+			}
+			if got := cliArgs(t, filepath.Join(dir, tt.want)); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s was called with\n%q\nwant\n%q", tt.want, got, want)
+			}
+		})
+	}
+}
+
+func TestDetectContainerRuntimeNone(t *testing.T) {
+	if _, err := os.Stat(strings.TrimPrefix(defaultDockerHost, "unix://")); err == nil {
+		t.Skip("the Docker Engine socket exists")
+	}
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("DOCKER_HOST", "")
+	if rt, err := DetectContainerRuntime(); !errors.Is(err, ErrNoContainerRuntime) {
+		t.Fatalf("DetectContainerRuntime() = %v, %v, want %v", rt, err, ErrNoContainerRuntime)
+	}
+}
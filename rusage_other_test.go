@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package standalone
+
+// maxRSS returns the peak resident set size of the process in bytes, which
+// is not available on this platform.
+func maxRSS() (int64, bool) {
+	return 0, false
+}
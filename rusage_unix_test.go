@@ -0,0 +1,22 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package standalone
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// maxRSS returns the peak resident set size of the process in bytes.
+func maxRSS() (int64, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, false
+	}
+	if runtime.GOOS == "darwin" {
+		return int64(ru.Maxrss), true
+	}
+	// Linux reports kilobytes.
+	return int64(ru.Maxrss) << 10, true
+}
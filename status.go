@@ -0,0 +1,243 @@
+package standalone
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// FileState is the state of an installed file compared to the manifest.
+type FileState string
+
+const (
+	FileOK       FileState = "ok"
+	FileModified FileState = "modified"
+	FileMissing  FileState = "missing"
+)
+
+// Status is the state of an installation.
+type Status struct {
+	InstallDir string `json:"installDir"`
+	// Installed is false if there is no install manifest.
+	Installed   bool              `json:"installed"`
+	Version     string            `json:"version,omitempty"`
+	InstalledAt *time.Time        `json:"installedAt,omitempty"`
+	Complete    bool              `json:"complete"`
+	Error       string            `json:"error,omitempty"`
+	Network     string            `json:"network,omitempty"`
+	Files       []FileStatus      `json:"files,omitempty"`
+	Containers  []ContainerStatus `json:"containers,omitempty"`
+}
+
+// FileStatus is the state of a file written by the installer.
+type FileStatus struct {
+	Path  string    `json:"path"`
+	State FileState `json:"state"`
+}
+
+// ContainerStatus is the state of a container started by the installer.
+type ContainerStatus struct {
+	Service Service `json:"service"`
+	Name    string  `json:"name"`
+	Image   string  `json:"image,omitempty"`
+	Exists  bool    `json:"exists"`
+	Running bool    `json:"running"`
+}
+
+// Healthy reports whether the install completed, none of its files is
+// missing and all of its containers are running. Modified files, e.g. an
+// edited config.yaml, are fine.
+func (s *Status) Healthy() bool {
+	if !s.Installed || !s.Complete {
+		return false
+	}
+	for _, f := range s.Files {
+		if f.State == FileMissing {
+			return false
+		}
+	}
+	for _, c := range s.Containers {
+		if !c.Running {
+			return false
+		}
+	}
+	return true
+}
+
+// Status compares the installation with its manifest and inspects the
+// containers it started.
+func (i *Installer) Status(ctx context.Context) (*Status, error) {
+	s := &Status{InstallDir: i.opts.InstallDir}
+	m, err := ReadManifest(i.opts.InstallDir)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if i.rtErr != nil {
+		return nil, i.rtErr
+	}
+
+	s.Installed = true
+	s.Version = m.Version
+	s.InstalledAt = &m.InstalledAt
+	s.Complete = m.Complete
+	s.Error = m.Error
+	s.Network = m.Network
+
+	for _, f := range m.Files {
+		state := FileOK
+		sum, err := fileSHA256(filepath.Join(i.opts.InstallDir, filepath.FromSlash(f.Path)))
+		switch {
+		case os.IsNotExist(err):
+			state = FileMissing
+		case err != nil:
+			return nil, err
+		case sum != f.SHA256:
+			state = FileModified
+		}
+		s.Files = append(s.Files, FileStatus{Path: f.Path, State: state})
+	}
+
+	for _, c := range m.Containers {
+		info, err := i.rt.Inspect(ctx, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		s.Containers = append(s.Containers, ContainerStatus{
+			Service: c.Service,
+			Name:    c.Name,
+			Image:   info.Image,
+			Exists:  info.Exists,
+			Running: info.Running,
+		})
+	}
+
+	return s, nil
+}
+
+// Verify checks the signature of the release manifest and the checksums
+// of the embedded assets without installing anything. Only the release of
+// Options.Version is checked, or every bundled release if it is empty.
+func (i *Installer) Verify(ctx context.Context) error {
+	if i.opts.Version != "" {
+		return i.prepare(ctx)
+	}
+	if err := i.verifyBundleSignature(); err != nil {
+		return err
+	}
+	m, err := loadBundleManifest()
+	if err != nil {
+		return err
+	}
+	for idx := range m.Releases {
+		i.bundle = &m.Releases[idx]
+		if err = i.verifyAssets(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract verifies the embedded assets and extracts the CLI and binaries
+// into dir, without touching the install directory or any container. It
+// returns the paths of the extracted files.
+func (i *Installer) Extract(ctx context.Context, dir string) ([]string, error) {
+	if err := i.prepare(ctx); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, err
+	}
+	i.step("Extracting CLI...")
+	files, err := i.installCLI(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	i.step("Extracting binaries...")
+	binFiles, err := i.installBinaries(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, binFiles...), nil
+}
+
+// AssetKind is the kind of an embedded asset.
+type AssetKind string
+
+const (
+	AssetCLI    AssetKind = "cli"
+	AssetBinary AssetKind = "binary"
+	AssetImage  AssetKind = "image"
+)
+
+// BundledAsset is an asset embedded in the installer.
+type BundledAsset struct {
+	Kind AssetKind `json:"kind"`
+	// Path is the path of the asset in the release manifest.
+	Path string `json:"path"`
+	// Service and Image are set for image archives.
+	Service Service `json:"service,omitempty"`
+	Image   string  `json:"image,omitempty"`
+	URL     string  `json:"url,omitempty"`
+	SHA256  string  `json:"sha256"`
+	Size    int64   `json:"size"`
+}
+
+// BundledAssets returns the assets of the bundled release of version, or
+// of the default release if version is empty, that are embedded for the
+// current platform. The version of the release is returned as well.
+func BundledAssets(version string) (string, []BundledAsset, error) {
+	b, err := loadBundle(version)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var bundled []BundledAsset
+	for _, kind := range []AssetKind{AssetCLI, AssetBinary} {
+		dir := "binaries"
+		if kind == AssetCLI {
+			dir = "cli"
+		}
+		for _, a := range b.platformAssets(dir) {
+			size, err := embeddedSize(assets, a.Path)
+			if err != nil {
+				return "", nil, err
+			}
+			bundled = append(bundled, BundledAsset{
+				Kind:   kind,
+				Path:   a.Path,
+				URL:    a.URL,
+				SHA256: a.SHA256,
+				Size:   size,
+			})
+		}
+	}
+	for _, img := range b.Images {
+		p := path.Join("images", img.File)
+		size, err := embeddedSize(imageArchives, p)
+		if err != nil {
+			return "", nil, err
+		}
+		bundled = append(bundled, BundledAsset{
+			Kind:    AssetImage,
+			Path:    p,
+			Service: img.Role,
+			Image:   img.Image,
+			SHA256:  img.SHA256,
+			Size:    size,
+		})
+	}
+	return b.Version, bundled, nil
+}
+
+func embeddedSize(fsys fs.FS, p string) (int64, error) {
+	fi, err := fs.Stat(fsys, p)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
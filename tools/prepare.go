@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 )
 
 var version = ""
@@ -20,22 +32,110 @@ type (
 	}
 
 	Release struct {
-		CLI      map[string]string   `json:"cli"`
-		Binaries map[string][]string `json:"binaries"`
-		Images   []string            `json:"images"`
+		CLI      map[string]Asset   `json:"cli"`
+		Binaries map[string][]Asset `json:"binaries"`
+		// Images maps the service an image runs to the image reference.
+		Images map[string]string `json:"images"`
+		// Checksums maps CLI and binary URLs to their expected SHA-256.
+		// URLs without an entry are checked against the upstream
+		// "<url>.sha256" file.
+		Checksums map[string]string `json:"checksums,omitempty"`
+		// PublicKey is the base64 encoded ed25519 key that signs the
+		// upstream artifacts. When set, the detached "<url>.sig"
+		// signatures are verified if they are published.
+		PublicKey string `json:"publicKey,omitempty"`
+	}
+
+	// Asset is a CLI or binary archive. In releases.json it is either
+	// a URL or an object that also sets the layout of its entries.
+	Asset struct {
+		URL string `json:"url"`
+		Layout
+	}
+
+	// Layout says where the entries of an archive are installed.
+	Layout struct {
+		// StripComponents is the number of leading path components
+		// removed from the entry names, like tar --strip-components.
+		StripComponents int `json:"stripComponents,omitempty"`
+		// StripPrefix is a leading path removed from the entry names.
+		// Entries outside of it are skipped.
+		StripPrefix string `json:"stripPrefix,omitempty"`
+		// Dir is the directory below the bin directory the entries
+		// are installed to.
+		Dir string `json:"dir,omitempty"`
+	}
+
+	// BundleManifest is the release manifest embedded in the installer.
+	BundleManifest struct {
+		// Default is the version installed unless another one is selected.
+		Default  string   `json:"default"`
+		Releases []Bundle `json:"releases"`
+	}
+
+	// Bundle is a release in the manifest.
+	Bundle struct {
+		Version string        `json:"version"`
+		Assets  []BundleAsset `json:"assets"`
+		Images  []BundleImage `json:"images"`
+	}
+
+	BundleAsset struct {
+		Path   string `json:"path"`
+		URL    string `json:"url"`
+		SHA256 string `json:"sha256"`
+		Layout
+	}
+
+	BundleImage struct {
+		Role   string `json:"role"`
+		Image  string `json:"image"`
+		File   string `json:"file"`
+		Digest string `json:"digest,omitempty"`
+		ID     string `json:"id,omitempty"`
+		SHA256 string `json:"sha256"`
 	}
 )
 
+func (a *Asset) UnmarshalJSON(b []byte) error {
+	var url string
+	if err := json.Unmarshal(b, &url); err == nil {
+		*a = Asset{URL: url}
+		return nil
+	}
+	type asset Asset
+	return json.Unmarshal(b, (*asset)(a))
+}
+
 func main() {
-	if version == "" {
+	versionList := flag.String("versions", version, "comma separated releases from releases.json to bundle")
+	defaultVersion := flag.String("default", "", "release installed unless another one is selected (default: the tagged version if bundled, otherwise the last one)")
+	flag.Parse()
+	if *versionList == "" {
 		log.Fatal("version is not set")
 	}
-	if err := prepare(version); err != nil {
+	versions := strings.Split(*versionList, ",")
+	if *defaultVersion == "" {
+		*defaultVersion = versions[len(versions)-1]
+		for _, v := range versions {
+			if v == version {
+				*defaultVersion = v
+			}
+		}
+	}
+
+	// Stop downloads and docker on Ctrl-C instead of leaving them behind.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := prepare(ctx, versions, *defaultVersion); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func prepare(version string) error {
+// stepTimeout bounds each download and docker command.
+const stepTimeout = 15 * time.Minute
+
+func prepare(ctx context.Context, versions []string, defaultVersion string) error {
 	configBytes, err := os.ReadFile("releases.json")
 	if err != nil {
 		return err
@@ -46,82 +146,425 @@ func prepare(version string) error {
 		return err
 	}
 
-	release := config.Releases[version]
-
-	fmt.Println("Saving images...")
-	if err = os.MkdirAll("images", 0775); err != nil {
-		return err
+	manifest := BundleManifest{Default: defaultVersion}
+	found := false
+	for _, v := range versions {
+		found = found || v == defaultVersion
 	}
-	for _, image := range release.Images {
-		if err = execute("docker", "pull", image); err != nil {
+	if !found {
+		return fmt.Errorf("the default release %s is not bundled", defaultVersion)
+	}
+
+	// Start from empty asset directories so that only the assets listed in
+	// bundle.json are embedded.
+	for _, dir := range []string{"images", "cli", "binaries"} {
+		if err = os.RemoveAll(dir); err != nil {
 			return err
 		}
-		filename := image + ".tar.gz"
-		filename = strings.ReplaceAll(filename, "/", "-")
-		filename = strings.ReplaceAll(filename, ":", "-")
-		if err = execute("docker", "save", "-o", filepath.Join("images", filename), image); err != nil {
+	}
+
+	store := newAssetStore()
+	for _, version := range versions {
+		release, ok := config.Releases[version]
+		if !ok {
+			return fmt.Errorf("release %s is not in releases.json", version)
+		}
+		fmt.Printf("Preparing %s...\n", version)
+		bundle, err := prepareRelease(ctx, store, version, release)
+		if err != nil {
 			return err
 		}
+		manifest.Releases = append(manifest.Releases, bundle)
 	}
 
-	fmt.Println("Downloading cli...")
-	for osarch, cliURL := range release.CLI {
-		osarchDir := filepath.Join("cli", osarch)
-		if err = os.MkdirAll(osarchDir, 0775); err != nil {
-			return err
+	fmt.Println("Writing bundle.json...")
+	bundleBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile("bundle.json", bundleBytes, 0644); err != nil {
+		return err
+	}
+
+	return signBundle(bundleBytes)
+}
+
+// assetStore deduplicates the assets releases have in common. Each URL and
+// image is fetched once, and content that is already stored under another
+// name is not stored again.
+type assetStore struct {
+	// urls maps the URLs downloaded so far to their assets.
+	urls map[string]BundleAsset
+	// paths maps checksums to the path the content is stored at.
+	paths map[string]string
+	// images maps image references to their saved archives.
+	images map[string]BundleImage
+	// imageFiles maps checksums to the image archive with that content.
+	imageFiles map[string]string
+}
+
+func newAssetStore() *assetStore {
+	return &assetStore{
+		urls:       map[string]BundleAsset{},
+		paths:      map[string]string{},
+		images:     map[string]BundleImage{},
+		imageFiles: map[string]string{},
+	}
+}
+
+// prepareRelease saves the images and downloads the CLI and binaries of a
+// release, reusing those already in store.
+func prepareRelease(ctx context.Context, store *assetStore, version string, release Release) (Bundle, error) {
+	bundle := Bundle{Version: version}
+
+	fmt.Println("Saving images...")
+	if err := os.MkdirAll("images", 0775); err != nil {
+		return Bundle{}, err
+	}
+	roles := make([]string, 0, len(release.Images))
+	for role := range release.Images {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		img, err := store.saveImage(ctx, release.Images[role])
+		if err != nil {
+			return Bundle{}, err
 		}
+		img.Role = role
+		bundle.Images = append(bundle.Images, img)
+	}
 
-		fmt.Println(cliURL)
-		filename := filepath.Base(cliURL)
-		target := filepath.Join(osarchDir, filename)
-		if err = downloadFile(target, cliURL); err != nil {
-			return err
+	fmt.Println("Downloading cli...")
+	for osarch, cli := range release.CLI {
+		asset, err := store.download(ctx, release, filepath.Join("cli", osarch, version), cli)
+		if err != nil {
+			return Bundle{}, err
 		}
+		bundle.Assets = append(bundle.Assets, asset)
 	}
 
 	fmt.Println("Downloading binaries...")
 	for osarch, binaries := range release.Binaries {
-		osarchDir := filepath.Join("binaries", osarch)
-		if err = os.MkdirAll(osarchDir, 0775); err != nil {
-			return err
+		for _, binary := range binaries {
+			asset, err := store.download(ctx, release, filepath.Join("binaries", osarch, version), binary)
+			if err != nil {
+				return Bundle{}, err
+			}
+			bundle.Assets = append(bundle.Assets, asset)
 		}
+	}
+
+	sort.Slice(bundle.Assets, func(i, j int) bool {
+		return bundle.Assets[i].Path < bundle.Assets[j].Path
+	})
+	return bundle, nil
+}
+
+// saveImage pulls and saves image unless it was saved for another release.
+func (s *assetStore) saveImage(ctx context.Context, image string) (BundleImage, error) {
+	if img, ok := s.images[image]; ok {
+		fmt.Printf("%s is already saved\n", image)
+		return img, nil
+	}
 
-		for _, binaryURL := range binaries {
-			fmt.Println(binaryURL)
-			filename := filepath.Base(binaryURL)
-			target := filepath.Join(osarchDir, filename)
-			if err = downloadFile(target, binaryURL); err != nil {
-				return err
+	if err := execute(ctx, "docker", "pull", image); err != nil {
+		return BundleImage{}, err
+	}
+	// Record what floating tags like "latest" resolved to so that the
+	// installer runs exactly this image.
+	digest, err := output(ctx, "docker", "image", "inspect", "--format", "{{index .RepoDigests 0}}", image)
+	if err != nil {
+		return BundleImage{}, err
+	}
+	id, err := output(ctx, "docker", "image", "inspect", "--format", "{{.Id}}", image)
+	if err != nil {
+		return BundleImage{}, err
+	}
+	fmt.Printf("%s resolved to %s\n", image, digest)
+	filename := image + ".tar.gz"
+	filename = strings.ReplaceAll(filename, "/", "-")
+	filename = strings.ReplaceAll(filename, ":", "-")
+	target := filepath.Join("images", filename)
+	if err = execute(ctx, "docker", "save", "-o", target, image); err != nil {
+		return BundleImage{}, err
+	}
+	sum, err := fileSHA256(target)
+	if err != nil {
+		return BundleImage{}, err
+	}
+	if existing, ok := s.imageFiles[sum]; ok {
+		if err = os.Remove(target); err != nil {
+			return BundleImage{}, err
+		}
+		filename = existing
+	} else {
+		s.imageFiles[sum] = filename
+	}
+
+	img := BundleImage{
+		Image:  image,
+		File:   filename,
+		Digest: digest,
+		ID:     id,
+		SHA256: sum,
+	}
+	s.images[image] = img
+	return img, nil
+}
+
+// download downloads a into dir and verifies it, unless its URL or its
+// content was already stored for another release.
+func (s *assetStore) download(ctx context.Context, release Release, dir string, a Asset) (BundleAsset, error) {
+	fmt.Println(a.URL)
+	if asset, ok := s.urls[a.URL]; ok {
+		if expected, ok := release.Checksums[a.URL]; ok && !strings.EqualFold(expected, asset.SHA256) {
+			return BundleAsset{}, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", a.URL, expected, asset.SHA256)
+		}
+		if release.PublicKey != "" {
+			if err := verifySignature(ctx, release.PublicKey, filepath.FromSlash(asset.Path), a.URL); err != nil {
+				return BundleAsset{}, err
 			}
 		}
+		// The layout belongs to the release, not the archive.
+		asset.Layout = a.Layout
+		return asset, nil
 	}
 
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return BundleAsset{}, err
+	}
+	target := filepath.Join(dir, filepath.Base(a.URL))
+	asset, err := downloadAsset(ctx, release, target, a)
+	if err != nil {
+		return BundleAsset{}, err
+	}
+	if existing, ok := s.paths[asset.SHA256]; ok {
+		if err = os.Remove(target); err != nil {
+			return BundleAsset{}, err
+		}
+		// Fails if other assets are stored in dir.
+		os.Remove(dir)
+		asset.Path = existing
+	} else {
+		s.paths[asset.SHA256] = asset.Path
+	}
+	s.urls[a.URL] = asset
+	return asset, nil
+}
+
+// signBundle writes the detached signature of the release manifest to
+// bundle.json.sig using the PEM encoded ed25519 private key in the file
+// named by BUNDLE_SIGNING_KEY_FILE. Without a key, the signature is empty.
+// If BUNDLE_PUBLIC_KEY is set, it must be the public key of the signing
+// key, since the installer is built with it and would reject the bundle.
+func signBundle(bundleBytes []byte) error {
+	keyFile := os.Getenv("BUNDLE_SIGNING_KEY_FILE")
+	if keyFile == "" {
+		fmt.Println("BUNDLE_SIGNING_KEY_FILE is not set, bundle.json is not signed")
+		return os.WriteFile("bundle.json.sig", nil, 0644)
+	}
+
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return fmt.Errorf("%s does not contain a PEM encoded key", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return fmt.Errorf("%s does not contain an ed25519 key", keyFile)
+	}
+
+	publicKey := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	if expected := os.Getenv("BUNDLE_PUBLIC_KEY"); expected != "" && expected != publicKey {
+		return fmt.Errorf("the key in %s does not match BUNDLE_PUBLIC_KEY", keyFile)
+	}
+
+	fmt.Println("Signing bundle.json...")
+	sig := ed25519.Sign(key, bundleBytes)
+	if err = os.WriteFile("bundle.json.sig", []byte(base64.StdEncoding.EncodeToString(sig)), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Public key: %s\n", publicKey)
 	return nil
 }
 
-func execute(prog string, args ...string) error {
-	cmd := exec.Command(prog, args...)
+func execute(ctx context.Context, prog string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, prog, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-func downloadFile(filepath string, url string) error {
-	// Get the data
-	resp, err := http.Get(url)
+func output(ctx context.Context, prog string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, prog, args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// downloadAsset downloads a to target and verifies its checksum.
+func downloadAsset(ctx context.Context, release Release, target string, a Asset) (BundleAsset, error) {
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+
+	url := a.URL
+	expected, ok := release.Checksums[url]
+	if !ok {
+		var err error
+		if expected, err = fetchChecksum(ctx, url+".sha256"); err != nil {
+			return BundleAsset{}, fmt.Errorf("no checksum for %s: %w", url, err)
+		}
+	}
+
+	sum, err := downloadFile(ctx, target, url)
+	if err != nil {
+		return BundleAsset{}, err
+	}
+	if !strings.EqualFold(sum, expected) {
+		os.Remove(target)
+		return BundleAsset{}, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, expected, sum)
+	}
+	if release.PublicKey != "" {
+		if err = verifySignature(ctx, release.PublicKey, target, url); err != nil {
+			os.Remove(target)
+			return BundleAsset{}, err
+		}
+	}
+
+	return BundleAsset{
+		Path:   filepath.ToSlash(target),
+		URL:    url,
+		SHA256: sum,
+		Layout: a.Layout,
+	}, nil
+}
+
+// verifySignature verifies the detached signature "<url>.sig" of the file
+// at target, if upstream publishes one.
+func verifySignature(ctx context.Context, publicKey, target, url string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key %q", publicKey)
+	}
+
+	resp, err := get(ctx, url+".sig")
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		fmt.Printf("%s is not signed\n", url)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s.sig: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return fmt.Errorf("%s.sig is not a base64 encoded signature", url)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("invalid signature for %s", url)
+	}
+	fmt.Printf("%s signature verified\n", url)
+	return nil
+}
+
+// fetchChecksum reads a checksum file in the "<sha256>  <filename>" format
+// of sha256sum, or one containing only the checksum.
+func fetchChecksum(ctx context.Context, url string) (string, error) {
+	resp, err := get(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("%s is not a checksum file", url)
+	}
+	return fields[0], nil
+}
+
+// get sends a GET request that is canceled with ctx.
+func get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// downloadFile downloads url to filepath and returns its SHA-256. A
+// partial download is removed.
+func downloadFile(ctx context.Context, filepath string, url string) (string, error) {
+	// Get the data
+	resp, err := get(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Do not save an error page as an asset
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
 
 	// Create the file
 	out, err := os.Create(filepath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer out.Close()
 
 	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return err
+	h := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(out, h), resp.Body); err != nil {
+		out.Close()
+		os.Remove(filepath)
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileSHA256(filepath string) (string, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSignBundle(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "signing-key.pem")
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bundle := []byte(`{"default":"v1.6.0"}`)
+
+	tests := []struct {
+		name      string
+		keyFile   string
+		publicKey string
+		wantErr   bool
+		// wantSigned is false for an empty signature.
+		wantSigned bool
+	}{
+		{name: "signed", keyFile: keyFile, wantSigned: true},
+		{name: "matching public key", keyFile: keyFile, publicKey: base64.StdEncoding.EncodeToString(public), wantSigned: true},
+		{name: "other public key", keyFile: keyFile, publicKey: base64.StdEncoding.EncodeToString(otherPublic), wantErr: true},
+		{name: "no signing key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, t.TempDir())
+			t.Setenv("BUNDLE_SIGNING_KEY_FILE", tt.keyFile)
+			t.Setenv("BUNDLE_PUBLIC_KEY", tt.publicKey)
+
+			err := signBundle(bundle)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("signBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			b, err := os.ReadFile("bundle.json.sig")
+			if tt.wantErr {
+				if !os.IsNotExist(err) {
+					t.Errorf("bundle.json.sig was written: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantSigned {
+				if len(b) > 0 {
+					t.Errorf("bundle.json.sig = %q, want it empty", b)
+				}
+				return
+			}
+			sig, err := base64.StdEncoding.DecodeString(string(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ed25519.Verify(public, bundle, sig) {
+				t.Error("the signature does not verify")
+			}
+		})
+	}
+}
+
+// chdir changes the working directory for the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestDownloadAsset(t *testing.T) {
+	body := []byte("dapr archive")
+	sum := sha256.Sum256(body)
+	checksum := hex.EncodeToString(sum[:])
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, body))
+	publicKey := base64.StdEncoding.EncodeToString(public)
+
+	tests := []struct {
+		name string
+		// files maps the paths the server serves to their contents; other
+		// paths are not found.
+		files     map[string]string
+		checksums map[string]string
+		publicKey string
+		wantErr   string
+	}{
+		{
+			name:      "checksum in releases.json",
+			files:     map[string]string{"/dapr.tar.gz": string(body)},
+			checksums: map[string]string{"/dapr.tar.gz": strings.ToUpper(checksum)},
+		},
+		{
+			name:  "upstream checksum file",
+			files: map[string]string{"/dapr.tar.gz": string(body), "/dapr.tar.gz.sha256": checksum + "  dapr.tar.gz\n"},
+		},
+		{
+			name:      "not found",
+			checksums: map[string]string{"/dapr.tar.gz": checksum},
+			wantErr:   "404 Not Found",
+		},
+		{
+			name:      "checksum mismatch",
+			files:     map[string]string{"/dapr.tar.gz": "<html>error page</html>"},
+			checksums: map[string]string{"/dapr.tar.gz": checksum},
+			wantErr:   "checksum mismatch",
+		},
+		{
+			name:    "upstream checksum mismatch",
+			files:   map[string]string{"/dapr.tar.gz": "tampered", "/dapr.tar.gz.sha256": checksum},
+			wantErr: "checksum mismatch",
+		},
+		{
+			name:    "no checksum",
+			files:   map[string]string{"/dapr.tar.gz": string(body)},
+			wantErr: "no checksum",
+		},
+		{
+			name:    "not a checksum file",
+			files:   map[string]string{"/dapr.tar.gz": string(body), "/dapr.tar.gz.sha256": "<html>"},
+			wantErr: "no checksum",
+		},
+		{
+			name:      "signed",
+			files:     map[string]string{"/dapr.tar.gz": string(body), "/dapr.tar.gz.sig": signature},
+			checksums: map[string]string{"/dapr.tar.gz": checksum},
+			publicKey: publicKey,
+		},
+		{
+			name:      "not signed upstream",
+			files:     map[string]string{"/dapr.tar.gz": string(body)},
+			checksums: map[string]string{"/dapr.tar.gz": checksum},
+			publicKey: publicKey,
+		},
+		{
+			name:      "invalid signature",
+			files:     map[string]string{"/dapr.tar.gz": string(body), "/dapr.tar.gz.sig": base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte("other")))},
+			checksums: map[string]string{"/dapr.tar.gz": checksum},
+			publicKey: publicKey,
+			wantErr:   "invalid signature",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				content, ok := tt.files[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				io.WriteString(w, content)
+			}))
+			defer srv.Close()
+
+			release := Release{Checksums: map[string]string{}, PublicKey: tt.publicKey}
+			for p, sum := range tt.checksums {
+				release.Checksums[srv.URL+p] = sum
+			}
+			target := filepath.Join(t.TempDir(), "dapr.tar.gz")
+			got, err := downloadAsset(context.Background(), release, target, Asset{URL: srv.URL + "/dapr.tar.gz"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("downloadAsset() error = %v, want %q", err, tt.wantErr)
+				}
+				// Nothing unverified is kept.
+				if _, err = os.Stat(target); !os.IsNotExist(err) {
+					t.Errorf("%s was kept: %v", target, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.SHA256 != checksum {
+				t.Errorf("SHA256 = %s, want %s", got.SHA256, checksum)
+			}
+			if b, _ := os.ReadFile(target); string(b) != string(body) {
+				t.Errorf("%s = %q, want %q", target, b, body)
+			}
+		})
+	}
+}
+
+func TestDownloadFileRejectsErrors(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusNoContent} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				io.WriteString(w, "error page")
+			}))
+			defer srv.Close()
+
+			target := filepath.Join(t.TempDir(), "dapr.tar.gz")
+			if _, err := downloadFile(context.Background(), target, srv.URL); err == nil {
+				t.Error("downloadFile() succeeded, want an error")
+			}
+			if _, err := os.Stat(target); !os.IsNotExist(err) {
+				t.Errorf("%s was written: %v", target, err)
+			}
+		})
+	}
+}
+
+func TestPrepareReleasesShareAssets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake docker is a shell script")
+	}
+	files := map[string]string{
+		"/v1/dapr.tar.gz":   "cli",
+		"/v2/dapr.tar.gz":   "cli",
+		"/dashboard.tar.gz": "dashboard",
+		"/v1/daprd.tar.gz":  "daprd 1",
+		"/v2/daprd.tar.gz":  "daprd 2",
+	}
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		io.WriteString(w, files[r.URL.Path])
+	}))
+	defer srv.Close()
+	checksums := map[string]string{}
+	for p, content := range files {
+		sum := sha256.Sum256([]byte(content))
+		checksums[srv.URL+p] = hex.EncodeToString(sum[:])
+	}
+
+	// The fake docker logs its arguments and saves an image as an archive
+	// that only depends on its tag.
+	bin := t.TempDir()
+	logFile := filepath.Join(bin, "docker.log")
+	script := `#!/bin/sh
+echo "$*" >> ` + logFile + `
+case "$1" in
+image) eval ref=\${$#}; case "$4" in *RepoDigests*) echo "${ref%:*}@sha256:0";; *) echo "sha256:${ref##*:}";; esac ;;
+save) printf 'image %s' "${4##*:}" > "$3" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	chdir(t, t.TempDir())
+
+	releases := []struct {
+		version string
+		release Release
+	}{
+		{"v1.5.1", Release{
+			CLI: map[string]Asset{"linux-amd64": {URL: srv.URL + "/v1/dapr.tar.gz"}},
+			Binaries: map[string][]Asset{"linux-amd64": {
+				{URL: srv.URL + "/v1/daprd.tar.gz"},
+				{URL: srv.URL + "/dashboard.tar.gz", Layout: Layout{StripComponents: 1}},
+			}},
+			Images:    map[string]string{"placement": "daprio/dapr:1.5.1", "redis": "redis:latest"},
+			Checksums: checksums,
+		}},
+		{"v1.6.0", Release{
+			// The same CLI under another URL.
+			CLI: map[string]Asset{"linux-amd64": {URL: srv.URL + "/v2/dapr.tar.gz"}},
+			Binaries: map[string][]Asset{"linux-amd64": {
+				{URL: srv.URL + "/v2/daprd.tar.gz"},
+				{URL: srv.URL + "/dashboard.tar.gz", Layout: Layout{StripPrefix: "release", Dir: "web"}},
+			}},
+			// The same placement image under another name.
+			Images:    map[string]string{"placement": "ghcr.io/dapr/dapr:1.5.1", "redis": "redis:latest"},
+			Checksums: checksums,
+		}},
+	}
+	store := newAssetStore()
+	var bundles []Bundle
+	for _, r := range releases {
+		b, err := prepareRelease(context.Background(), store, r.version, r.release)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundles = append(bundles, b)
+	}
+
+	asset := func(b Bundle, name string) BundleAsset {
+		for _, a := range b.Assets {
+			if strings.HasSuffix(a.URL, name) {
+				return a
+			}
+		}
+		t.Fatalf("%s has no asset %s", b.Version, name)
+		return BundleAsset{}
+	}
+	image := func(b Bundle, role string) BundleImage {
+		for _, img := range b.Images {
+			if img.Role == role {
+				return img
+			}
+		}
+		t.Fatalf("%s has no %s image", b.Version, role)
+		return BundleImage{}
+	}
+	v1, v2 := bundles[0], bundles[1]
+
+	if a1, a2 := asset(v1, "dapr.tar.gz"), asset(v2, "dapr.tar.gz"); a1.Path != "cli/linux-amd64/v1.5.1/dapr.tar.gz" || a2.Path != a1.Path {
+		t.Errorf("the CLI is stored at %s and %s, want both at cli/linux-amd64/v1.5.1/dapr.tar.gz", a1.Path, a2.Path)
+	}
+	d1, d2 := asset(v1, "dashboard.tar.gz"), asset(v2, "dashboard.tar.gz")
+	if d1.Path != d2.Path {
+		t.Errorf("the dashboard is stored at %s and %s, want it stored once", d1.Path, d2.Path)
+	}
+	if d1.Layout != (Layout{StripComponents: 1}) || d2.Layout != (Layout{StripPrefix: "release", Dir: "web"}) {
+		t.Errorf("dashboard layouts = %+v and %+v, want those of each release", d1.Layout, d2.Layout)
+	}
+	if requests["/dashboard.tar.gz"] != 1 {
+		t.Errorf("the dashboard was downloaded %d times, want once", requests["/dashboard.tar.gz"])
+	}
+	if asset(v1, "daprd.tar.gz").Path == asset(v2, "daprd.tar.gz").Path {
+		t.Error("different daprd archives are stored at the same path")
+	}
+
+	if r1, r2 := image(v1, "redis"), image(v2, "redis"); r1 != r2 {
+		t.Errorf("redis images = %+v and %+v, want the same", r1, r2)
+	}
+	p1, p2 := image(v1, "placement"), image(v2, "placement")
+	if p1.File != p2.File || p1.Image == p2.Image {
+		t.Errorf("placement images = %+v and %+v, want another name for the same file", p1, p2)
+	}
+	b, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "pull redis:latest"); n != 1 {
+		t.Errorf("redis:latest was pulled %d times, want once", n)
+	}
+
+	var stored []string
+	for _, dir := range []string{"binaries", "cli", "images"} {
+		err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() {
+				stored = append(stored, filepath.ToSlash(p))
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"binaries/linux-amd64/v1.5.1/daprd.tar.gz",
+		"binaries/linux-amd64/v1.5.1/dashboard.tar.gz",
+		"binaries/linux-amd64/v1.6.0/daprd.tar.gz",
+		"cli/linux-amd64/v1.5.1/dapr.tar.gz",
+		"images/daprio-dapr-1.5.1.tar.gz",
+		"images/redis-latest.tar.gz",
+	}
+	if !reflect.DeepEqual(stored, want) {
+		t.Errorf("stored %q, want %q", stored, want)
+	}
+}
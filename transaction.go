@@ -0,0 +1,102 @@
+package standalone
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// transaction records the changes made by an install so that they can be
+// undone if a later step fails.
+type transaction struct {
+	i        *Installer
+	undo     []action
+	finalize []action
+}
+
+type action struct {
+	desc string
+	fn   func(ctx context.Context) error
+}
+
+// onRollback registers fn to undo a change. Rollback actions run in reverse order.
+func (t *transaction) onRollback(desc string, fn func(ctx context.Context) error) {
+	t.undo = append(t.undo, action{desc: desc, fn: fn})
+}
+
+// onCommit registers fn to run once every step has succeeded, e.g. to
+// discard a backup that is no longer needed.
+func (t *transaction) onCommit(desc string, fn func(ctx context.Context) error) {
+	t.finalize = append(t.finalize, action{desc: desc, fn: fn})
+}
+
+// commit runs the commit actions. Failures are reported but do not fail
+// the install since the new state is already in place.
+func (t *transaction) commit(ctx context.Context) {
+	for _, a := range t.finalize {
+		if err := a.fn(ctx); err != nil {
+			t.i.warn("could not %s: %v", a.desc, err)
+		}
+	}
+}
+
+// rollback runs the rollback actions in reverse order. All actions are
+// attempted; the errors of the ones that fail are combined.
+func (t *transaction) rollback(ctx context.Context) error {
+	if len(t.undo) == 0 {
+		return nil
+	}
+	t.i.step("Rolling back...")
+	var failed []string
+	for idx := len(t.undo) - 1; idx >= 0; idx-- {
+		a := t.undo[idx]
+		t.i.item("%s", a.desc)
+		if err := a.fn(ctx); err != nil {
+			failed = append(failed, fmt.Sprintf("could not %s: %v", a.desc, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("rollback failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// swapDir replaces dir with newDir. The previous contents of dir are kept
+// in a backup directory whose path is returned, or "" if dir did not exist.
+func swapDir(newDir, dir string) (string, error) {
+	backup := dir + ".old"
+	if err := os.RemoveAll(backup); err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		backup = ""
+	} else if err != nil {
+		return "", err
+	} else if err = os.Rename(dir, backup); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(newDir, dir); err != nil {
+		if backup != "" {
+			if rerr := os.Rename(backup, dir); rerr != nil {
+				return "", fmt.Errorf("%w (could not restore %s: %v)", err, dir, rerr)
+			}
+		}
+		return "", err
+	}
+
+	return backup, nil
+}
+
+// restoreDir undoes swapDir.
+func restoreDir(dir, backup string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if backup == "" {
+		return nil
+	}
+	return os.Rename(backup, dir)
+}
@@ -0,0 +1,200 @@
+package standalone
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UninstallOptions controls how much of an installation Uninstall removes.
+// The service containers are always removed.
+type UninstallOptions struct {
+	// RemoveImages removes the images the installs of every version
+	// loaded, except those that existed before.
+	RemoveImages bool
+	// RemoveFiles removes the binaries of every installed version, the
+	// backups of upgrades, config.yaml and the components created by the
+	// installer. Other component files are kept.
+	RemoveFiles bool
+	// Purge removes the whole install directory, including user-authored
+	// component files. Implies RemoveFiles.
+	Purge bool
+}
+
+// Uninstall reverses Install according to uo.
+func (i *Installer) Uninstall(ctx context.Context, uo UninstallOptions) error {
+	if i.rtErr != nil {
+		return i.rtErr
+	}
+
+	network := i.opts.Network
+	manifest, err := ReadManifest(i.opts.InstallDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if network == "" && manifest != nil {
+		// Default to the network the containers were installed on.
+		network = manifest.Network
+	}
+
+	manifests, err := readManifests(i.opts.InstallDir)
+	if err != nil {
+		return err
+	}
+	// The images of every installed version, not only those of the
+	// containers, were loaded by the installer.
+	images := loadedImages(manifests)
+
+	i.step("Removing %s containers...", i.rt.Name())
+	for _, name := range serviceContainerNames {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		container := createContainerName(name, network)
+		info, err := i.rt.Inspect(ctx, container)
+		if err != nil {
+			return err
+		}
+		if !info.Exists {
+			continue
+		}
+		if err = i.removeDockerContainer(ctx, name, network); err != nil {
+			return fmt.Errorf("could not remove container %s: %w", container, err)
+		}
+		if len(manifests) == 0 {
+			// Without a manifest, fall back to the images of the containers.
+			images = append(images, info.Image)
+		}
+	}
+
+	if manifest != nil && manifest.NetworkCreated && manifest.Network == network {
+		i.emit(Event{Kind: EventMessage, Message: "Removing network: " + network, Name: network})
+		// Other containers may still be attached to it.
+		if err = i.rt.RemoveNetwork(ctx, network); err != nil {
+			i.warn("could not remove network %s: %v", network, err)
+		}
+	}
+
+	if uo.RemoveImages {
+		i.step("Removing %s images...", i.rt.Name())
+		present, err := i.rt.Images(ctx)
+		if err != nil {
+			return err
+		}
+		for _, image := range images {
+			if !newImageSet(present).contains(image) {
+				continue
+			}
+			i.emit(Event{Kind: EventItem, Message: image, Name: image})
+			if err := i.rt.RemoveImage(ctx, image); err != nil {
+				return fmt.Errorf("could not remove image %s: %w", image, err)
+			}
+		}
+	}
+
+	if uo.Purge {
+		// The install directory may be any directory the user chose, so
+		// only remove it if an install created it.
+		if manifest == nil {
+			return fmt.Errorf("refusing to remove %s, it does not contain an install manifest", i.opts.InstallDir)
+		}
+		i.step("Removing %s...", i.opts.InstallDir)
+		return os.RemoveAll(i.opts.InstallDir)
+	}
+	if uo.RemoveFiles {
+		i.step("Removing files...")
+		return i.removeFiles(manifest)
+	}
+
+	return nil
+}
+
+// Uninstall removes the containers, images and files of the default
+// installation. User-authored component files are kept.
+func Uninstall() error {
+	installer, err := NewInstaller(Options{})
+	if err != nil {
+		return err
+	}
+	return installer.Uninstall(context.Background(), UninstallOptions{
+		RemoveImages: true,
+		RemoveFiles:  true,
+	})
+}
+
+var serviceContainerNames = []string{
+	DaprPlacementContainerName,
+	DaprRedisContainerName,
+	DaprZipkinContainerName,
+}
+
+// removeFiles removes the binaries and the configuration and components
+// the installer created, which the manifest lists. Files that already
+// existed when Dapr was installed are user-authored and kept.
+func (i *Installer) removeFiles(manifest *Manifest) error {
+	// The bin directory and current are links into, or copies of, the
+	// versions directory, which holds the binaries of every installed version.
+	if err := removeEntry(i.binDir); err != nil {
+		return err
+	}
+	if err := removeEntry(filepath.Join(i.opts.InstallDir, currentLinkName)); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(i.opts.InstallDir, versionsDirName)); err != nil {
+		return err
+	}
+	// The backups of the install directory are created by upgrades.
+	if err := os.RemoveAll(filepath.Join(i.opts.InstallDir, backupsDirName)); err != nil {
+		return err
+	}
+
+	// Without a manifest, e.g. for an install by an older installer, fall
+	// back to the files the installer creates.
+	created := []string{
+		i.configPath,
+		filepath.Join(i.compDir, pubSubYamlFileName),
+		filepath.Join(i.compDir, stateStoreYamlFileName),
+	}
+	if manifest != nil {
+		created = nil
+		for _, f := range manifest.Files {
+			if !strings.HasPrefix(f.Path, versionsDirName+"/") {
+				created = append(created, filepath.Join(i.opts.InstallDir, filepath.FromSlash(f.Path)))
+			}
+		}
+	}
+	for _, p := range append(created, filepath.Join(i.opts.InstallDir, ManifestFileName)) {
+		if err := removeIfExists(p); err != nil {
+			return err
+		}
+	}
+
+	// Only remove the directories if nothing user-authored is left in them.
+	for _, dir := range []string{i.compDir, i.opts.InstallDir} {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			i.message("Keeping %s, it is not empty", dir)
+			continue
+		}
+		if err = os.Remove(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeIfExists(filePath string) error {
+	err := os.Remove(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package standalone
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUninstallRemoveFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		// created lists the files the manifest records, nil for an
+		// install without a manifest.
+		created []string
+		want    []string
+	}{
+		{
+			name:    "keeps components the user wrote before installing",
+			created: []string{"config.yaml", "components/statestore.yaml"},
+			want:    []string{"components/pubsub.yaml", "components/mine.yaml"},
+		},
+		{
+			name:    "keeps config.yaml the user wrote before installing",
+			created: []string{"components/pubsub.yaml", "components/statestore.yaml"},
+			want:    []string{"config.yaml", "components/mine.yaml"},
+		},
+		{
+			name: "removes the files the installer writes without a manifest",
+			want: []string{"components/mine.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range []string{"config.yaml", "components/pubsub.yaml", "components/statestore.yaml", "components/mine.yaml", "versions/v1.6.0/bin/dapr", "backups/v1.5.1-20220101T000000Z/config.yaml"} {
+				writeTestFile(t, filepath.Join(dir, f), "x")
+			}
+			if tt.created != nil {
+				m := &Manifest{Version: "v1.6.0", Complete: true}
+				for _, f := range tt.created {
+					m.addFile(ManifestFile{Path: f})
+				}
+				if err := m.write(dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			i := newTestInstaller(t, newFakeRuntime(), Options{InstallDir: dir})
+			if err := i.Uninstall(context.Background(), UninstallOptions{RemoveFiles: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(dir, p)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return err
+			})
+			if !sameStrings(got, tt.want) {
+				t.Errorf("left %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUninstallKeepsComponentsAcrossInstalls(t *testing.T) {
+	useTestBundle(t, "v1.6.0")
+	dir := t.TempDir()
+	mine := filepath.Join(dir, "components", "statestore.yaml")
+	writeTestFile(t, mine, "mine")
+
+	rt := newFakeRuntime()
+	for n := 0; n < 2; n++ {
+		// The second install finds the files of the first one.
+		rt.queueLoads("v1.6.0", AllServices...)
+		if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n"}).Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := newTestInstaller(t, rt, Options{InstallDir: dir}).Uninstall(context.Background(), UninstallOptions{RemoveFiles: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := os.ReadFile(mine); err != nil || string(b) != "mine" {
+		t.Errorf("statestore.yaml = %q, %v, want the user's", b, err)
+	}
+	for _, f := range []string{"config.yaml", "components/pubsub.yaml", "bin", "versions"} {
+		if _, err := os.Lstat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", f, err)
+		}
+	}
+	// The first install created the network.
+	if rt.networks["n"] {
+		t.Error("network n was not removed")
+	}
+}
+
+func TestUninstallPurgeRequiresManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "notes.txt"), "x")
+	i := newTestInstaller(t, newFakeRuntime(), Options{InstallDir: dir})
+	if err := i.Uninstall(context.Background(), UninstallOptions{Purge: true}); err == nil {
+		t.Error("Uninstall() purged a directory without a manifest")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error(err)
+	}
+}
+
+func writeTestFile(t testing.TB, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sameStrings compares a and b ignoring the order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[string]int{}
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+		if count[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUninstallRemovesImagesOfEveryVersion(t *testing.T) {
+	useTestBundle(t, "v1.6.0", "v1.5.1")
+	dir := t.TempDir()
+	rt := newFakeRuntime()
+	// The user had pulled this image before installing.
+	mine := testImage(ServiceZipkin, "v1.5.1")
+	rt.images[mine] = "sha256:mine"
+
+	rt.queueLoads("v1.5.1", AllServices...)
+	if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", Version: "v1.5.1"}).Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rt.queueLoads("v1.6.0", AllServices...)
+	if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", Version: "v1.6.0"}).Upgrade(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n"}).Use(context.Background(), "v1.5.1"); err != nil {
+		t.Fatal(err)
+	}
+	// Reinstalling finds the images it loaded before.
+	rt.queueLoads("v1.5.1", AllServices...)
+	if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", Version: "v1.5.1"}).Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	err := newTestInstaller(t, rt, Options{InstallDir: dir}).Uninstall(context.Background(), UninstallOptions{RemoveFiles: true, RemoveImages: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for image := range rt.images {
+		left = append(left, image)
+	}
+	if !sameStrings(left, []string{mine}) {
+		t.Errorf("images left = %v, want only %s", left, mine)
+	}
+	if len(rt.containers) > 0 {
+		t.Errorf("containers left = %v", rt.containers)
+	}
+}
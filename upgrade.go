@@ -0,0 +1,329 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupsDirName is the directory under the install directory that holds
+// a copy of the install directory per upgrade.
+const backupsDirName = "backups"
+
+// maxBackups is how many backups of the install directory are kept.
+const maxBackups = 3
+
+// ErrNotInstalled is returned when upgrading an install directory that
+// holds neither an install manifest nor the Dapr CLI.
+var ErrNotInstalled = errors.New("dapr is not installed")
+
+// Upgrade upgrades the installed version, read from the install manifest,
+// to Options.Version or the default bundled version.
+//
+// The install directory is first backed up to backups/<version>-<time>,
+// then config.yaml and the component files are migrated to the schema of
+// the new version and the new version is installed like Install does,
+// with the network, ports and services of the existing install. User
+// edited files are kept, and the Redis and Zipkin containers are replaced
+// if they run an image other than the one bundled with the new version.
+// If the install fails, the migrated files are restored from the backup.
+// Only the last maxBackups backups are kept.
+//
+// An install without a manifest, e.g. one by an older installer, is
+// upgraded from Options.From or the version its CLI reports, with the
+// network and ports in Options and the services whose containers exist.
+func (i *Installer) Upgrade(ctx context.Context) error {
+	m, err := ReadManifest(i.opts.InstallDir)
+	if os.IsNotExist(err) {
+		m, err = i.legacyManifest(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if err = i.selectVersion(); err != nil {
+		return err
+	}
+	from, to := m.Version, i.opts.Version
+	switch c := compareVersions(from, to); {
+	case c == 0 && m.Complete:
+		i.emit(Event{Kind: EventDone, Message: fmt.Sprintf("Dapr %s is already installed.", to), Name: to})
+		return nil
+	case c > 0:
+		return fmt.Errorf("the installed Dapr %s is newer than %s, install or use %s instead", from, to, to)
+	}
+
+	i.step("Upgrading Dapr %s to %s", from, to)
+
+	// Recreate the containers the way they were installed.
+	i.opts.Network = m.Network
+	i.opts.Services = []Service{}
+	for _, c := range m.Containers {
+		if !containsService(i.opts.Services, c.Service) {
+			i.opts.Services = append(i.opts.Services, c.Service)
+		}
+	}
+	if port := m.Ports[ServicePlacement]; port != 0 {
+		i.opts.PlacementPort = port
+	}
+	if port := m.Ports[ServiceRedis]; port != 0 {
+		i.opts.RedisPort = port
+	}
+	if port := m.Ports[ServiceZipkin]; port != 0 {
+		i.opts.ZipkinPort = port
+	}
+
+	backupDir, err := i.backupInstallDir(from)
+	if err != nil {
+		return fmt.Errorf("could not back up %s: %w", i.opts.InstallDir, err)
+	}
+	i.message("Backed up %s to %s", i.opts.InstallDir, backupDir)
+
+	migrated, err := i.migrateFiles(from, to)
+	if err == nil {
+		i.upgrading = true
+		err = i.Install(ctx)
+	}
+	if err != nil {
+		if rerr := i.restoreFiles(backupDir, migrated); rerr != nil {
+			err = fmt.Errorf("%w (%v)", err, rerr)
+		}
+		return err
+	}
+
+	// Install carries over the entries of the files the previous install
+	// created, with their checksums from before the migration.
+	for _, p := range migrated {
+		rel, err := filepath.Rel(i.opts.InstallDir, p)
+		if err != nil {
+			return err
+		}
+		if _, ok := i.manifest.file(filepath.ToSlash(rel)); !ok {
+			continue
+		}
+		if err = i.manifest.addFiles(i.opts.InstallDir, p); err != nil {
+			return err
+		}
+	}
+	if err = i.manifest.write(i.opts.InstallDir); err != nil {
+		return err
+	}
+
+	// The upgrade succeeded, so failing to prune is not an error.
+	if err = i.pruneBackups(); err != nil {
+		i.warn("could not remove old backups: %v", err)
+	}
+	return nil
+}
+
+// pruneBackups removes all but the newest maxBackups backups.
+func (i *Installer) pruneBackups() error {
+	dir := filepath.Join(i.opts.InstallDir, backupsDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type backup struct {
+		name    string
+		modTime time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return err
+		}
+		backups = append(backups, backup{e.Name(), fi.ModTime()})
+	}
+	// The names start with the version, so order by time.
+	sort.Slice(backups, func(a, b int) bool {
+		if !backups[a].modTime.Equal(backups[b].modTime) {
+			return backups[a].modTime.After(backups[b].modTime)
+		}
+		return backups[a].name > backups[b].name
+	})
+	for idx := maxBackups; idx < len(backups); idx++ {
+		if err = os.RemoveAll(filepath.Join(dir, backups[idx].name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyManifest describes an install without a manifest: its version is
+// Options.From or the runtime version its CLI reports, and its containers
+// are the service containers that exist on the network in Options.
+func (i *Installer) legacyManifest(ctx context.Context) (*Manifest, error) {
+	cli := filepath.Join(i.binDir, "dapr")
+	if runtime.GOOS == "windows" {
+		cli += ".exe"
+	}
+	if _, err := os.Stat(cli); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w in %s", ErrNotInstalled, i.opts.InstallDir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Version: i.opts.From, Network: i.opts.Network}
+	if m.Version == "" {
+		out, err := RunCmdAndWaitContext(ctx, cli, "--version")
+		if err != nil {
+			return nil, fmt.Errorf("could not read the installed version, set the version to upgrade from: %w", err)
+		}
+		if m.Version = parseRuntimeVersion(out); m.Version == "" {
+			return nil, fmt.Errorf("could not read the installed version from %q, set the version to upgrade from", strings.TrimSpace(out))
+		}
+	}
+	if !strings.HasPrefix(m.Version, "v") {
+		m.Version = "v" + m.Version
+	}
+
+	if i.rtErr != nil {
+		return nil, i.rtErr
+	}
+	for _, s := range AllServices {
+		name := createContainerName(serviceContainers[s], m.Network)
+		info, err := i.rt.Inspect(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if info.Exists {
+			m.Containers = append(m.Containers, ManifestContainer{Service: s, Name: name, Image: info.Image})
+		}
+	}
+	return m, nil
+}
+
+// parseRuntimeVersion returns the runtime version in the output of
+// dapr --version, e.g. "1.5.1" for "Runtime version: 1.5.1", or "" if it
+// shows none.
+func parseRuntimeVersion(out string) string {
+	const prefix = "Runtime version:"
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		if v := strings.TrimSpace(line[len(prefix):]); v != "n/a" {
+			return v
+		}
+	}
+	return ""
+}
+
+// backupInstallDir copies the install directory, except the versions and
+// backups, to a new directory under backups and returns its path.
+func (i *Installer) backupInstallDir(version string) (string, error) {
+	root := i.opts.InstallDir
+	if err := os.MkdirAll(filepath.Join(root, backupsDirName), 0775); err != nil {
+		return "", err
+	}
+	name := version + "-" + time.Now().UTC().Format("20060102T150405Z")
+	backupDir := filepath.Join(root, backupsDirName, name)
+	// Never reuse the backup of an earlier upgrade.
+	err := os.Mkdir(backupDir, 0775)
+	for n := 2; os.IsExist(err); n++ {
+		backupDir = filepath.Join(root, backupsDirName, fmt.Sprintf("%s-%d", name, n))
+		err = os.Mkdir(backupDir, 0775)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Without a current version, bin is that of an install from before
+	// versions were kept side by side and is backed up.
+	current, err := i.currentVersion()
+	if err != nil {
+		os.RemoveAll(backupDir)
+		return "", err
+	}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		switch {
+		case rel == versionsDirName || rel == backupsDirName:
+			return filepath.SkipDir
+		case rel == currentLinkName || rel == "bin" && (isLink(d.Type()) || current != ""):
+			// The links into, or copies of, versions, which are kept.
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && strings.HasPrefix(rel, ".bin-staging-") {
+			// Left behind by an interrupted install.
+			return filepath.SkipDir
+		}
+		dst := filepath.Join(backupDir, rel)
+		switch {
+		case isLink(d.Type()):
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dst)
+		case d.IsDir():
+			return os.Mkdir(dst, 0775)
+		}
+		return copyFile(p, dst)
+	})
+	if err != nil {
+		os.RemoveAll(backupDir)
+		return "", err
+	}
+	return backupDir, nil
+}
+
+// restoreFiles copies the files in paths back from backupDir.
+func (i *Installer) restoreFiles(backupDir string, paths []string) error {
+	var failed []string
+	for _, p := range paths {
+		rel, err := filepath.Rel(i.opts.InstallDir, p)
+		if err == nil {
+			err = copyFile(filepath.Join(backupDir, rel), p)
+		}
+		if err != nil {
+			failed = append(failed, p)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not restore %s from %s", strings.Join(failed, ", "), backupDir)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,181 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpgradeLegacyInstall(t *testing.T) {
+	useTestBundle(t, "v1.6.0")
+	const statestore = "# mine\nkind: Component\nmetadata:\n  name: statestore\nspec:\n  type: state.redis\n"
+	tests := []struct {
+		name string
+		// from is Options.From.
+		from string
+		// version is the output of bin/dapr --version, "" for a CLI that
+		// cannot be run.
+		version  string
+		noCLI    bool
+		fail     string
+		wantFrom string
+		wantErr  error
+	}{
+		{name: "version option", from: "0.11.3", wantFrom: "v0.11.3"},
+		{name: "version of the CLI", version: "CLI version: 1.0.0\nRuntime version: 0.11.3\n", wantFrom: "v0.11.3"},
+		{name: "runtime not installed", version: "CLI version: 1.0.0\nRuntime version: n/a\n"},
+		{name: "no CLI", noCLI: true, wantErr: ErrNotInstalled},
+		{name: "install fails", from: "v0.11.3", fail: "Run " + createContainerName(DaprPlacementContainerName, "n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.version != "" && runtime.GOOS == "windows" {
+				t.Skip("the fake CLI is a shell script")
+			}
+			dir := t.TempDir()
+			cli := filepath.Join(dir, "bin", "dapr")
+			if runtime.GOOS == "windows" {
+				cli += ".exe"
+			}
+			if !tt.noCLI {
+				writeTestFile(t, cli, "#!/bin/sh\nprintf '"+strings.ReplaceAll(tt.version, "\n", `\n`)+"'\n")
+				if err := os.Chmod(cli, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeTestFile(t, filepath.Join(dir, "components", "statestore.yaml"), statestore)
+			rt := newFakeRuntime()
+			for _, s := range []Service{ServicePlacement, ServiceRedis} {
+				rt.containers[createContainerName(serviceContainers[s], "n")] = ContainerInfo{Exists: true, Running: true, Image: testImage(s, "v0.11.3")}
+			}
+			rt.queueLoads("v1.6.0", ServicePlacement, ServiceRedis)
+			if tt.fail != "" {
+				rt.fail[tt.fail] = errors.New("failed")
+			}
+
+			i := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", From: tt.from})
+			err := i.Upgrade(context.Background())
+			if tt.wantFrom == "" {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Upgrade() error = %v, want %v", err, tt.wantErr)
+				}
+				// Nothing is changed.
+				if b, _ := os.ReadFile(filepath.Join(dir, "components", "statestore.yaml")); string(b) != statestore {
+					t.Errorf("statestore.yaml = %q, want it unchanged", b)
+				}
+				if _, err := os.Stat(filepath.Join(dir, ManifestFileName)); !os.IsNotExist(err) {
+					t.Errorf("a manifest was written: %v", err)
+				}
+				if tt.fail != "" {
+					if b, _ := os.ReadFile(cli); !strings.HasPrefix(string(b), "#!/bin/sh") {
+						t.Errorf("bin/dapr was not restored")
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := ReadManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Version != "v1.6.0" || len(m.Containers) != 2 {
+				t.Errorf("manifest = %+v, want v1.6.0 with placement and redis", m)
+			}
+			if _, ok := m.file("components/statestore.yaml"); ok {
+				t.Error("the user's statestore.yaml is listed as created by the installer")
+			}
+			if b, _ := os.ReadFile(filepath.Join(dir, "components", "statestore.yaml")); !strings.Contains(string(b), "# mine") || !strings.Contains(string(b), "version: v1") {
+				t.Errorf("statestore.yaml was not migrated:\n%s", b)
+			}
+			if v, err := i.currentVersion(); err != nil || v != "v1.6.0" {
+				t.Errorf("currentVersion() = %q, %v", v, err)
+			}
+
+			// The legacy bin directory and the files from before the
+			// migration are backed up.
+			backups, err := filepath.Glob(filepath.Join(dir, backupsDirName, tt.wantFrom+"-*"))
+			if err != nil || len(backups) != 1 {
+				t.Fatalf("backups = %v, %v", backups, err)
+			}
+			if b, _ := os.ReadFile(filepath.Join(backups[0], "components", "statestore.yaml")); string(b) != statestore {
+				t.Errorf("backed up statestore.yaml = %q", b)
+			}
+			if _, err := os.Stat(filepath.Join(backups[0], "bin", filepath.Base(cli))); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseRuntimeVersion(t *testing.T) {
+	tests := []struct {
+		out, want string
+	}{
+		{"CLI version: 1.5.0 \nRuntime version: 1.5.1\n", "1.5.1"},
+		{"CLI version: 1.5.0\r\nRuntime version: 1.5.1\r\n", "1.5.1"},
+		{"CLI version: 1.5.0 \nRuntime version: n/a\n", ""},
+		{"dapr version 0.5.0", ""},
+	}
+	for _, tt := range tests {
+		if got := parseRuntimeVersion(tt.out); got != tt.want {
+			t.Errorf("parseRuntimeVersion(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestUpgradePrunesBackups(t *testing.T) {
+	useTestBundle(t, "v1.6.0", "v1.5.1")
+	dir := t.TempDir()
+	rt := newFakeRuntime()
+	rt.queueLoads("v1.5.1", AllServices...)
+	if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", Version: "v1.5.1"}).Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Backups of earlier upgrades, the oldest first.
+	var old []string
+	for n, name := range []string{"v1.5.0-20220301T000000Z", "v1.4.4-20220201T000000Z", "v1.5.0-20220101T000000Z"} {
+		p := filepath.Join(dir, backupsDirName, name)
+		writeTestFile(t, filepath.Join(p, "config.yaml"), "x")
+		modTime := time.Now().Add(-time.Duration(n+1) * time.Hour)
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		old = append(old, name)
+	}
+
+	rt.queueLoads("v1.6.0", AllServices...)
+	if err := newTestInstaller(t, rt, Options{InstallDir: dir, Version: "v1.6.0"}).Upgrade(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, backupsDirName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if len(got) != maxBackups {
+		t.Fatalf("backups = %v, want %d", got, maxBackups)
+	}
+	// The oldest backup is removed and the new one is kept.
+	kept := false
+	for _, name := range got {
+		if name == old[2] {
+			t.Errorf("the oldest backup %s was kept", name)
+		}
+		kept = kept || strings.HasPrefix(name, "v1.5.1-")
+	}
+	if !kept {
+		t.Errorf("the new backup was removed: %v", got)
+	}
+}
@@ -0,0 +1,108 @@
+package standalone
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ChecksumError is returned when an embedded asset does not match the
+// checksum recorded in the release manifest.
+type ChecksumError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("%s is not listed in the release manifest", e.Path)
+	}
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// ErrSignature is returned when the release manifest is not signed by the
+// embedded public key.
+var ErrSignature = errors.New("release manifest signature is invalid")
+
+// verifyBundleSignature checks that the release manifest was signed with
+// the key matching bundlePublicKey. Installers built without a public key
+// skip the check with a warning.
+func (i *Installer) verifyBundleSignature() error {
+	if bundlePublicKey == "" {
+		i.warn("this installer was built without a public key, skipping signature verification")
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(bundlePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: the embedded public key is malformed", ErrSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(bundleSignature)))
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("%w: the release manifest is not signed", ErrSignature)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), bundleJSON, sig) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// verifyAssets checks the embedded CLI, binaries and images against the
+// checksums in the release manifest before anything is installed.
+func (i *Installer) verifyAssets(ctx context.Context) error {
+	i.step("Verifying Dapr %s assets...", i.bundle.Version)
+
+	cli, err := i.bundle.cliAsset()
+	if err != nil {
+		return err
+	}
+	// Only the listed assets are installed, so the checksums of those are
+	// all that matters.
+	for _, a := range append([]bundleAsset{cli}, i.bundle.binaryAssets()...) {
+		sum, err := embeddedSHA256(ctx, assets, a.Path)
+		if err != nil {
+			return err
+		}
+		if sum != a.SHA256 {
+			return &ChecksumError{Path: a.Path, Expected: a.SHA256, Actual: sum}
+		}
+	}
+
+	for _, img := range i.bundle.Images {
+		if !i.hasService(img.Role) {
+			continue
+		}
+		p := path.Join("images", img.File)
+		sum, err := embeddedSHA256(ctx, imageArchives, p)
+		if err != nil {
+			return err
+		}
+		if sum != img.SHA256 {
+			return &ChecksumError{Path: p, Expected: img.SHA256, Actual: sum}
+		}
+	}
+
+	return nil
+}
+
+func embeddedSHA256(ctx context.Context, fsys fs.FS, p string) (string, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, contextReader{ctx, f}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,143 @@
+package standalone
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInstallVerifiesSignature(t *testing.T) {
+	useTestBundle(t, "v1.6.0")
+	public, private := generateTestKey(t)
+	otherPublic, _ := generateTestKey(t)
+	signed := bundleJSON
+	signature := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(private, signed)))
+
+	tests := []struct {
+		name      string
+		publicKey string
+		manifest  []byte
+		signature []byte
+		wantErr   error
+	}{
+		{name: "accepted", publicKey: public, manifest: signed, signature: signature},
+		{name: "tampered", publicKey: public, manifest: append([]byte(" "), signed...), signature: signature, wantErr: ErrSignature},
+		{name: "wrong key", publicKey: otherPublic, manifest: signed, signature: signature, wantErr: ErrSignature},
+		{name: "unsigned", publicKey: public, manifest: signed, wantErr: ErrSignature},
+		{name: "malformed key", publicKey: "bm90IGEga2V5", manifest: signed, signature: signature, wantErr: ErrSignature},
+		{name: "built without a key", manifest: signed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savedKey := bundlePublicKey
+			bundlePublicKey, bundleJSON, bundleSignature = tt.publicKey, tt.manifest, tt.signature
+			defer func() { bundlePublicKey, bundleJSON, bundleSignature = savedKey, signed, nil }()
+
+			dir := t.TempDir()
+			rt := newFakeRuntime()
+			rt.queueLoads("v1.6.0", AllServices...)
+			err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n"}).Install(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Install() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			// Nothing is installed before the signature is verified.
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+				t.Errorf("install directory = %v, %v, want it empty", entries, err)
+			}
+			if len(rt.calls) > 0 {
+				t.Errorf("runtime calls = %v, want none", rt.calls)
+			}
+		})
+	}
+}
+
+// generateTestKey returns a new base64 encoded ed25519 public key and its
+// private key.
+func generateTestKey(t testing.TB) (string, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(public), private
+}
+
+func TestInstallVerifiesChecksums(t *testing.T) {
+	tests := []struct {
+		name string
+		// tamper changes the release manifest of the test bundle.
+		tamper   func(b *bundle)
+		wantPath string
+	}{
+		{
+			name:     "CLI",
+			tamper:   func(b *bundle) { b.Assets[0].SHA256 = strings.Repeat("0", 64) },
+			wantPath: firstFile(t, assets, "cli"),
+		},
+		{
+			name:     "binaries",
+			tamper:   func(b *bundle) { b.Assets[1].SHA256 = strings.Repeat("0", 64) },
+			wantPath: firstFile(t, assets, "binaries"),
+		},
+		{
+			name: "image",
+			tamper: func(b *bundle) {
+				for idx := range b.Images {
+					b.Images[idx].SHA256 = strings.Repeat("0", 64)
+				}
+			},
+			wantPath: firstFile(t, imageArchives, "images"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestBundle(t, "v1.6.0")
+			var m bundleManifest
+			if err := json.Unmarshal(bundleJSON, &m); err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(&m.Releases[0])
+			b, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bundleJSON = b
+
+			dir := t.TempDir()
+			rt := newFakeRuntime()
+			rt.queueLoads("v1.6.0", AllServices...)
+			err = newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n"}).Install(context.Background())
+			var checksumErr *ChecksumError
+			if !errors.As(err, &checksumErr) {
+				t.Fatalf("Install() error = %v, want a ChecksumError", err)
+			}
+			if checksumErr.Path != tt.wantPath || checksumErr.Actual != embeddedSum(t, fsFor(tt.wantPath), tt.wantPath) {
+				t.Errorf("ChecksumError = %+v, want %s", checksumErr, tt.wantPath)
+			}
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+				t.Errorf("install directory = %v, %v, want it empty", entries, err)
+			}
+			if len(rt.calls) > 0 {
+				t.Errorf("runtime calls = %v, want none", rt.calls)
+			}
+		})
+	}
+}
+
+// fsFor returns the embedded file system that holds p.
+func fsFor(p string) fs.FS {
+	if strings.HasPrefix(p, "images/") {
+		return imageArchives
+	}
+	return assets
+}
@@ -0,0 +1,339 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// versionsDirName is the directory under the install directory that
+	// holds a directory per installed version, e.g. versions/v1.6.0/bin.
+	versionsDirName = "versions"
+	// currentLinkName is the link to the directory of the version in use,
+	// or the file naming it where links are not available.
+	currentLinkName = "current"
+)
+
+// ErrVersionNotInstalled is returned when switching to a version that is
+// not installed.
+var ErrVersionNotInstalled = errors.New("version is not installed")
+
+// InstalledVersion is a version installed side by side with others.
+type InstalledVersion struct {
+	Version string `json:"version"`
+	// Current is true for the version the bin directory points to.
+	Current     bool       `json:"current"`
+	InstalledAt *time.Time `json:"installedAt,omitempty"`
+}
+
+// versionDir returns the directory of version.
+func (i *Installer) versionDir(version string) string {
+	return filepath.Join(i.opts.InstallDir, versionsDirName, version)
+}
+
+// currentVersion returns the version current points to, or "" if there
+// is none.
+func (i *Installer) currentVersion() (string, error) {
+	p := filepath.Join(i.opts.InstallDir, currentLinkName)
+	fi, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if fi.Mode().IsRegular() {
+		// Written instead of the link where links are not available.
+		b, err := os.ReadFile(p)
+		return strings.TrimSpace(string(b)), err
+	}
+	target, err := os.Readlink(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(target), nil
+}
+
+// Versions lists the installed versions.
+func (i *Installer) Versions() ([]InstalledVersion, error) {
+	entries, err := os.ReadDir(filepath.Join(i.opts.InstallDir, versionsDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	current, err := i.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []InstalledVersion
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v := InstalledVersion{Version: e.Name(), Current: e.Name() == current}
+		// A version without a manifest is still being installed or its
+		// install was rolled back before the manifest was written.
+		m, err := ReadManifest(i.versionDir(e.Name()))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		v.InstalledAt = &m.InstalledAt
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(a, b int) bool {
+		return versions[a].InstalledAt.Before(*versions[b].InstalledAt)
+	})
+	return versions, nil
+}
+
+// Use switches to an installed version: the current link is pointed at
+// its directory and the placement container is replaced by one running
+// the placement image of that version. Like Install, the switch is
+// rolled back if it fails.
+func (i *Installer) Use(ctx context.Context, version string) (err error) {
+	target, err := ReadManifest(i.versionDir(version))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrVersionNotInstalled, version)
+	} else if err != nil {
+		return err
+	}
+	m, err := ReadManifest(i.opts.InstallDir)
+	if err != nil {
+		return err
+	}
+
+	i.step("Switching to Dapr %s", version)
+
+	i.manifest = m
+	tx := &transaction{i: i}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		if err != nil {
+			if rerr := tx.rollback(cleanupCtx); rerr != nil {
+				err = fmt.Errorf("%w (%v)", err, rerr)
+			}
+			return
+		}
+		tx.commit(cleanupCtx)
+		if werr := m.write(i.opts.InstallDir); werr != nil {
+			err = fmt.Errorf("could not write install manifest: %w", werr)
+		}
+	}()
+
+	if err = i.setCurrent(tx, version); err != nil {
+		return err
+	}
+
+	// The files of the previous version are replaced by those of the new one.
+	prefix := versionsDirName + "/"
+	files := m.Files[:0]
+	for _, f := range m.Files {
+		if !strings.HasPrefix(f.Path, prefix) {
+			files = append(files, f)
+		}
+	}
+	for _, f := range target.Files {
+		if strings.HasPrefix(f.Path, prefix+version+"/") {
+			files = append(files, f)
+		}
+	}
+	m.Files = files
+	m.Version = version
+
+	if err = i.usePlacement(ctx, tx, target); err != nil {
+		return err
+	}
+
+	i.emit(Event{Kind: EventDone, Message: fmt.Sprintf("Dapr %s is in use.", version), Name: version})
+	return nil
+}
+
+// usePlacement replaces the placement container with one running the
+// placement image recorded in the manifest of the version switched to.
+func (i *Installer) usePlacement(ctx context.Context, tx *transaction, target *Manifest) error {
+	m := i.manifest
+	idx := -1
+	for n, c := range m.Containers {
+		if c.Service == ServicePlacement {
+			idx = n
+		}
+	}
+	image := ""
+	for _, c := range target.Containers {
+		if c.Service == ServicePlacement {
+			image = c.Image
+		}
+	}
+	if idx < 0 || image == "" || image == m.Containers[idx].Image {
+		// Placement is not installed, or already runs the right image.
+		return nil
+	}
+
+	if i.rtErr != nil {
+		return i.rtErr
+	}
+	if _, err := i.rt.ImageID(ctx, image); err != nil {
+		return fmt.Errorf("the placement image of %s is no longer present, install %s again: %w", target.Version, target.Version, err)
+	}
+
+	// Recreate the container the way it was installed.
+	i.opts.Network = m.Network
+	i.opts.Services = []Service{ServicePlacement}
+	if port := m.Ports[ServicePlacement]; port != 0 {
+		i.opts.PlacementPort = port
+	}
+	m.Containers = append(m.Containers[:idx], m.Containers[idx+1:]...)
+
+	err := i.withStepTimeout(ctx, func(ctx context.Context) error {
+		if err := i.backupContainer(ctx, tx, DaprPlacementContainerName); err != nil {
+			return fmt.Errorf("could not stop placement service: %w", err)
+		}
+		i.step("Starting %s containers...", i.rt.Name())
+		i.emit(Event{Kind: EventItem, Message: "Dapr placement service", Service: ServicePlacement})
+		return i.trackContainer(ctx, tx, ServicePlacement, DaprPlacementContainerName, func() error {
+			return runPlacementService(ctx, i.rt, image, i.opts.Network, i.opts.PlacementPort)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("could not start placement service: %w", err)
+	}
+	return i.waitForServices(ctx)
+}
+
+// setCurrent points current at the directory of version and makes the
+// bin directory hold the binaries of that version. Both are links into the
+// versions directory where the platform allows it; otherwise current is a
+// file naming the version and bin a copy of its binaries.
+func (i *Installer) setCurrent(tx *transaction, version string) error {
+	currentPath := filepath.Join(i.opts.InstallDir, currentLinkName)
+	linked := true
+	err := replaceEntry(tx, currentPath, func(name string) error {
+		if err := linkDir(filepath.Join(versionsDirName, version), name); err != nil {
+			i.warn("could not link %s, recording the version in use instead: %v", currentPath, err)
+			linked = false
+			return os.WriteFile(name, []byte(version+"\n"), 0644)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Lstat(i.binDir)
+	if err == nil && linked && isLink(fi.Mode()) {
+		// bin links to current/bin, which now holds the new binaries.
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return replaceEntry(tx, i.binDir, func(name string) error {
+		if linked {
+			err := linkDir(filepath.Join(currentLinkName, "bin"), name)
+			if err == nil {
+				return nil
+			}
+			i.warn("could not link %s, copying the binaries instead: %v", i.binDir, err)
+		}
+		return copyDir(filepath.Join(i.versionDir(version), "bin"), name)
+	})
+}
+
+// linkDir creates the link name to the directory target. It is a variable
+// so that the tests can make it fail.
+var linkDir = createLink
+
+// isLink reports whether mode is that of a symbolic link or, on Windows,
+// a directory junction.
+func isLink(mode fs.FileMode) bool {
+	return mode&(fs.ModeSymlink|fs.ModeIrregular) != 0
+}
+
+// replaceEntry replaces name with the file, directory or link create
+// makes. The previous one is restored on rollback and removed on commit.
+func replaceEntry(tx *transaction, name string, create func(name string) error) error {
+	tmp, backup := name+".new", name+".old"
+	for _, p := range []string{tmp, backup} {
+		if err := removeEntry(p); err != nil {
+			return err
+		}
+	}
+	if err := create(tmp); err != nil {
+		removeEntry(tmp)
+		return err
+	}
+
+	if _, err := os.Lstat(name); os.IsNotExist(err) {
+		backup = ""
+	} else if err != nil {
+		removeEntry(tmp)
+		return err
+	} else if err = os.Rename(name, backup); err != nil {
+		removeEntry(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		removeEntry(tmp)
+		if backup != "" {
+			if rerr := os.Rename(backup, name); rerr != nil {
+				return fmt.Errorf("%w (could not restore %s: %v)", err, name, rerr)
+			}
+		}
+		return err
+	}
+
+	tx.onRollback("restore "+name, func(context.Context) error {
+		if err := removeEntry(name); err != nil || backup == "" {
+			return err
+		}
+		return os.Rename(backup, name)
+	})
+	if backup != "" {
+		tx.onCommit("remove "+backup, func(context.Context) error {
+			return removeEntry(backup)
+		})
+	}
+	return nil
+}
+
+// removeEntry removes the file, link or directory p. Links are removed
+// without touching what they point to.
+func removeEntry(p string) error {
+	fi, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.IsDir() && !isLink(fi.Mode()) {
+		return os.RemoveAll(p)
+	}
+	return os.Remove(p)
+}
+
+// copyDir copies the directory src to dst, which must not exist.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.Mkdir(filepath.Join(dst, rel), 0775)
+		}
+		return copyFile(p, filepath.Join(dst, rel))
+	})
+}
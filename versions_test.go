@@ -0,0 +1,156 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetCurrent(t *testing.T) {
+	tests := []struct {
+		name string
+		// bin is what the bin directory is before switching: "" if it
+		// does not exist, "legacy" for the directory of an install from
+		// before versions were kept side by side, "link" for a link to
+		// current/bin.
+		bin       string
+		linkFails bool
+		rollback  bool
+	}{
+		{name: "first install"},
+		{name: "legacy bin directory", bin: "legacy"},
+		{name: "switch", bin: "link"},
+		{name: "first install without links", linkFails: true},
+		{name: "legacy bin directory without links", bin: "legacy", linkFails: true},
+		{name: "switch from links to copies", bin: "link", linkFails: true},
+		{name: "rollback of a switch", bin: "link", rollback: true},
+		{name: "rollback of a legacy bin directory", bin: "legacy", linkFails: true, rollback: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, v := range []string{"v1.5.1", "v1.6.0"} {
+				writeTestFile(t, filepath.Join(dir, versionsDirName, v, "bin", "dapr"), v)
+			}
+			i := newTestInstaller(t, newFakeRuntime(), Options{InstallDir: dir})
+			before := ""
+			switch tt.bin {
+			case "legacy":
+				writeTestFile(t, filepath.Join(dir, "bin", "dapr"), "legacy")
+				before = "legacy"
+			case "link":
+				tx := &transaction{i: i}
+				if err := i.setCurrent(tx, "v1.5.1"); err != nil {
+					t.Fatal(err)
+				}
+				tx.commit(context.Background())
+				before = "v1.5.1"
+			}
+
+			if tt.linkFails {
+				linkDir = func(target, name string) error { return errors.New("not allowed") }
+				defer func() { linkDir = createLink }()
+			}
+			tx := &transaction{i: i}
+			if err := i.setCurrent(tx, "v1.6.0"); err != nil {
+				t.Fatal(err)
+			}
+			want := "v1.6.0"
+			if tt.rollback {
+				if err := tx.rollback(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				want = before
+			} else {
+				tx.commit(context.Background())
+			}
+
+			if b, _ := os.ReadFile(filepath.Join(dir, "bin", "dapr")); string(b) != want {
+				t.Errorf("bin/dapr = %q, want %q", b, want)
+			}
+			if want == "legacy" {
+				want = ""
+			}
+			if got, err := i.currentVersion(); err != nil || got != want {
+				t.Errorf("currentVersion() = %q, %v, want %q", got, err, want)
+			}
+			if fi, err := os.Lstat(filepath.Join(dir, "bin")); err == nil && !tt.rollback && isLink(fi.Mode()) == tt.linkFails {
+				t.Errorf("bin is a link: %v, want %v", isLink(fi.Mode()), !tt.linkFails)
+			}
+			for _, name := range []string{"bin.old", "bin.new", "current.old", "current.new"} {
+				if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s was left behind: %v", name, err)
+				}
+			}
+			// The binaries of every version are kept.
+			for _, v := range []string{"v1.5.1", "v1.6.0"} {
+				if b, _ := os.ReadFile(filepath.Join(dir, versionsDirName, v, "bin", "dapr")); string(b) != v {
+					t.Errorf("%s/bin/dapr = %q", v, b)
+				}
+			}
+		})
+	}
+}
+
+func TestUse(t *testing.T) {
+	useTestBundle(t, "v1.6.0", "v1.5.1")
+	dir := t.TempDir()
+	rt := newFakeRuntime()
+	// The services run on a network so that no ports are probed.
+	for _, v := range []string{"v1.5.1", "v1.6.0"} {
+		rt.queueLoads(v, AllServices...)
+		if err := newTestInstaller(t, rt, Options{InstallDir: dir, Network: "n", Version: v}).Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	placement := createContainerName(DaprPlacementContainerName, "n")
+	tests := []struct {
+		name    string
+		version string
+		// fail makes a runtime call fail.
+		fail    string
+		wantErr error
+		// want is the version in use afterwards.
+		want string
+	}{
+		{name: "not installed", version: "v1.4.0", wantErr: ErrVersionNotInstalled, want: "v1.6.0"},
+		{name: "placement fails", version: "v1.5.1", fail: "Run " + placement, want: "v1.6.0"},
+		{name: "switch", version: "v1.5.1", want: "v1.5.1"},
+		{name: "switch back", version: "v1.6.0", want: "v1.6.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt.fail = map[string]error{}
+			if tt.fail != "" {
+				rt.fail[tt.fail] = errors.New("failed")
+			}
+			i := newTestInstaller(t, rt, Options{InstallDir: dir})
+			err := i.Use(context.Background(), tt.version)
+			if tt.fail != "" || tt.wantErr != nil {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Use() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := i.currentVersion(); err != nil || got != tt.want {
+				t.Errorf("currentVersion() = %q, %v, want %q", got, err, tt.want)
+			}
+			m, err := ReadManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Version != tt.want {
+				t.Errorf("manifest version = %s, want %s", m.Version, tt.want)
+			}
+			c := rt.containers[placement]
+			if want := testImage(ServicePlacement, tt.want); !c.Running || c.Image != want {
+				t.Errorf("placement = %+v, want %s running", c, want)
+			}
+		})
+	}
+}